@@ -0,0 +1,351 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"time"
+
+	"greenlight/internal/data"
+	"greenlight/internal/validator"
+)
+
+// the audit log record of what an admin did, it is given to the model
+// so it is written in the same transaction as the change
+func (app *application) auditEntry(r *http.Request, action, targetType string, targetID int64, details map[string]any) *data.AuditEntry{
+	actor := app.contextGetUser(r)
+
+	return &data.AuditEntry{
+		ActorID: &actor.ID,
+		Action: action,
+		TargetType: targetType,
+		TargetID: targetID,
+		Details: details,
+		IP: app.clientIP(r),
+	}
+}
+
+// read the user from the :id parameter and send the error response if
+// there is no such user
+func (app *application) readAdminTargetUser(w http.ResponseWriter, r *http.Request) (*data.User, bool){
+	id, err := app.readIDParam(r)
+	if err != nil{
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	user, err := app.models.Users.Get(id)
+	if err != nil{
+		switch{
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+
+	return user, true
+}
+
+// log the user out everywhere, used when the account is changed by an admin.
+// entry is written with the revocation and can be nil
+func (app *application) revokeAllUserTokens(userID int64, entry *data.AuditEntry) error{
+	deny := app.userDenial(userID)
+
+	err := app.models.Tokens.RevokeAllForUser(userID, deny, entry)
+	if err != nil{
+		return err
+	}
+
+	if deny != nil{
+		app.denylist.add(deny)
+	}
+	return nil
+}
+
+// the deny list entry which revoke every jwt of the user issued until now,
+// nil in database mode
+func (app *application) userDenial(userID int64) *data.DeniedToken{
+	if app.config.auth.mode != authModeJWT{
+		return nil
+	}
+
+	now := time.Now()
+
+	return &data.DeniedToken{
+		UserID: userID,
+		RevokedAt: now,
+		Expiry: now.Add(app.config.tokens.authenticationTTL),
+	}
+}
+
+func (app *application) listAdminUsersHandler(w http.ResponseWriter, r *http.Request){
+	var input struct{
+		Name string
+		Email string
+		Activated *bool
+		data.Filters
+	}
+
+	v := validator.New()
+
+	qs := r.URL.Query()
+
+	input.Name = app.readStirng(qs, "name", "")
+	input.Email = app.readStirng(qs, "email", "")
+	input.Activated = app.readBool(qs, "activated", v)
+
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+
+	input.Filters.Sort = app.readStirng(qs, "sort", "id")
+	input.Filters.SortSafelist = []string{"id", "name", "email", "created_at", "-id", "-name", "-email", "-created_at"}
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid(){
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	users, metadata, err := app.models.Users.GetAll(input.Name, input.Email, input.Activated, input.Filters)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"users": users, "metadata": metadata}, nil)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) showAdminUserHandler(w http.ResponseWriter, r *http.Request){
+	user, ok := app.readAdminTargetUser(w, r)
+	if !ok{
+		return
+	}
+
+	err := app.writeJSON(w, http.StatusOK, envelope{"user": user}, nil)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) updateAdminUserActivationHandler(w http.ResponseWriter, r *http.Request){
+	user, ok := app.readAdminTargetUser(w, r)
+	if !ok{
+		return
+	}
+
+	var input struct{
+		Activated *bool `json:"activated"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil{
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	if v.Check(input.Activated != nil, "activated", "must be provided"); !v.Valid(){
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	// a user going from inactive to active get the default permissions like
+	// one activated by token, jwt carry the activated flag so a deactivated
+	// user lose every session
+	var grant []string
+	var deny *data.DeniedToken
+	if *input.Activated && !user.Activated{
+		grant = app.config.defaultPermissions
+	}
+	if !*input.Activated{
+		deny = app.userDenial(user.ID)
+	}
+
+	user.Activated = *input.Activated
+
+	entry := app.auditEntry(r, "user.activation", "user", user.ID, map[string]any{"activated": user.Activated})
+
+	err = app.models.Users.UpdateActivation(user, grant, deny, entry)
+	if err != nil{
+		switch{
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if deny != nil{
+		app.denylist.add(deny)
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"user": user}, nil)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// the password is replaced by a random one nobody know, every session is
+// revoked and the user get a password reset email
+func (app *application) forceAdminUserPasswordResetHandler(w http.ResponseWriter, r *http.Request){
+	user, ok := app.readAdminTargetUser(w, r)
+	if !ok{
+		return
+	}
+
+	randomBytes := make([]byte, 32)
+	_, err := rand.Read(randomBytes)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = user.Password.Set(base64.RawStdEncoding.EncodeToString(randomBytes))
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.models.Users.UpdateAudited(user, app.auditEntry(r, "user.password_reset", "user", user.ID, nil))
+	if err != nil{
+		switch{
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.revokeAllUserTokens(user.ID, nil)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	token, err := app.models.Tokens.New(user.ID, 45*time.Minute, data.ScopePasswordReset)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.backgroud(func(){
+		data := map[string]any{
+			"passwordResetToken": token.Plaintext,
+		}
+
+		err = app.mailer.Send(user.Email, "token_password_reset.tmpl", data)
+		if err != nil{
+			app.logger.PrintError(err, nil)
+		}
+	})
+
+	err = app.writeJSON(w, http.StatusAccepted, envelope{"message": "the password was reset and the user will receive reset instructions"}, nil)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteAdminUserTokensHandler(w http.ResponseWriter, r *http.Request){
+	user, ok := app.readAdminTargetUser(w, r)
+	if !ok{
+		return
+	}
+
+	err := app.revokeAllUserTokens(user.ID, app.auditEntry(r, "user.tokens_revoked", "user", user.ID, nil))
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "all tokens of the user were revoked"}, nil)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteAdminUserHandler(w http.ResponseWriter, r *http.Request){
+	user, ok := app.readAdminTargetUser(w, r)
+	if !ok{
+		return
+	}
+
+	// the audit record need the actor to still exist, admins delete there
+	// own account with DELETE /v1/users/me
+	if user.ID == app.contextGetUser(r).ID{
+		v := validator.New()
+		v.AddError("id", "use DELETE /v1/users/me to delete your own account")
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err := app.denyAllJWTForUser(user.ID)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// the email is kept in the record since the user row is gone
+	entry := app.auditEntry(r, "user.deleted", "user", user.ID, map[string]any{"email": user.Email})
+
+	err = app.models.Users.Delete(user.ID, entry)
+	if err != nil{
+		switch{
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "user successfully deleted"}, nil)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// erasure asked to support by the data subject, unlike a delete the
+// audit record keep nothing about the user
+func (app *application) eraseAdminUserHandler(w http.ResponseWriter, r *http.Request){
+	user, ok := app.readAdminTargetUser(w, r)
+	if !ok{
+		return
+	}
+
+	if user.ID == app.contextGetUser(r).ID{
+		v := validator.New()
+		v.AddError("id", "use DELETE /v1/users/me to delete your own account")
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err := app.denyAllJWTForUser(user.ID)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.models.Users.Erase(user, app.auditEntry(r, "user.erased", "user", user.ID, nil))
+	if err != nil{
+		switch{
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "user data successfully erased"}, nil)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+	}
+}
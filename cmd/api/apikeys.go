@@ -0,0 +1,121 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"greenlight/internal/data"
+	"greenlight/internal/validator"
+)
+
+func (app *application) createAPIKeyHandler(w http.ResponseWriter, r *http.Request){
+	user := app.contextGetUser(r)
+
+	var input struct{
+		Name string `json:"name"`
+		Permissions []string `json:"permissions"`
+		Expiry *time.Time `json:"expiry"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil{
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	key := &data.APIKey{
+		UserID: user.ID,
+		Name: input.Name,
+		Permissions: input.Permissions,
+		Expiry: input.Expiry,
+	}
+
+	v := validator.New()
+
+	if data.ValidateAPIKey(v, key); !v.Valid(){
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	// a restricted credential can't make a key with every permission of the
+	// user, that would give it more than it has
+	if len(key.Permissions) == 0 && app.contextIsDelegated(r){
+		v.AddError("permissions", "must be provided when using a restricted api key or token")
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	// a key can only get permissions the caller has, the permissions in the
+	// context are already restricted to what the credential can use
+	if len(key.Permissions) > 0{
+		permissions, ok := app.contextGetPermissions(r)
+		if !ok{
+			var err error
+			permissions, err = app.models.Permissions.GetEffectiveForUser(user.ID)
+			if err != nil{
+				app.serverErrorResponse(w, r, err)
+				return
+			}
+		}
+
+		if len(permissions.Restrict(key.Permissions)) != len(key.Permissions){
+			v.AddError("permissions", "must only contain permissions you have")
+			app.failedValidationResponse(w, r, v.Errors)
+			return
+		}
+	}
+
+	err = app.models.APIKeys.Insert(key)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// the plaintext key is only shown in this response
+	err = app.writeJSON(w, http.StatusCreated, envelope{"api_key": key}, nil)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) listAPIKeysHandler(w http.ResponseWriter, r *http.Request){
+	user := app.contextGetUser(r)
+
+	keys, err := app.models.APIKeys.GetAllForUser(user.ID)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"api_keys": keys}, nil)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteAPIKeyHandler(w http.ResponseWriter, r *http.Request){
+	user := app.contextGetUser(r)
+
+	id, err := app.readIDParam(r)
+	if err != nil{
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.models.APIKeys.Delete(id, user.ID)
+	if err != nil{
+		switch{
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "api key successfully revoked"}, nil)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+	}
+}
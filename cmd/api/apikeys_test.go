@@ -0,0 +1,37 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"greenlight/internal/data"
+)
+
+// a restricted credential must not be able to make a key stronger than itself
+func TestCreateAPIKeyWithRestrictedCredential(t *testing.T){
+	app := newTestApplication(t)
+
+	tests := []struct{
+		name string
+		body string
+	}{
+		{"more permissions", `{"name": "ci", "permissions": ["movies:read", "movies:write"]}`},
+		{"every permission", `{"name": "ci"}`},
+	}
+
+	for _, tt := range tests{
+		t.Run(tt.name, func(t *testing.T){
+			req := httptest.NewRequest(http.MethodPost, "/v1/users/me/api-keys", strings.NewReader(tt.body))
+			req = app.contextSetUser(req, &data.User{ID: 1, Activated: true})
+			req = app.contextSetPermissions(req, data.Permissions{"movies:read"})
+			req = app.contextSetDelegated(req)
+
+			rr := httptest.NewRecorder()
+			app.createAPIKeyHandler(rr, req)
+
+			assertStatus(t, rr.Code, http.StatusUnprocessableEntity)
+		})
+	}
+}
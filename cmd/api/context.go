@@ -12,6 +12,19 @@ type contextKey string
 //Convert the string "user" to a contextKey type and assign it to user Contextkey
 const userContextKey = contextKey("user")
 
+// the plaintext of the authentication token used for the request
+const tokenContextKey = contextKey("token")
+
+// permissions of the user when we already know them
+const permissionsContextKey = contextKey("permissions")
+
+// organization the session was switched to
+const organizationContextKey = contextKey("organization")
+
+// set when the credential can only use part of the user permissions (api
+// key with permissions or oauth token)
+const delegatedContextKey = contextKey("delegated")
+
 
 // we add user struct to request as context
 func (app *application) contextSetUser(r *http.Request, user *data.User) *http.Request{
@@ -27,5 +40,46 @@ func (app *application) contextGetUser(r *http.Request) *data.User{
 	return user
 }
 
+func (app *application) contextSetToken(r *http.Request, tokenPlaintext string) *http.Request{
+	ctx := context.WithValue(r.Context(), tokenContextKey, tokenPlaintext)
+	return r.WithContext(ctx)
+}
 
+// return empty string when request was not made with a token
+func (app *application) contextGetToken(r *http.Request) string{
+	token, _ := r.Context().Value(tokenContextKey).(string)
+	return token
+}
 
+func (app *application) contextSetPermissions(r *http.Request, permissions data.Permissions) *http.Request{
+	ctx := context.WithValue(r.Context(), permissionsContextKey, permissions)
+	return r.WithContext(ctx)
+}
+
+func (app *application) contextGetPermissions(r *http.Request) (data.Permissions, bool){
+	permissions, ok := r.Context().Value(permissionsContextKey).(data.Permissions)
+	return permissions, ok
+}
+
+func (app *application) contextSetOrganization(r *http.Request, orgID int64) *http.Request{
+	ctx := context.WithValue(r.Context(), organizationContextKey, orgID)
+	return r.WithContext(ctx)
+}
+
+// ok is false when the session has no organization
+func (app *application) contextGetOrganization(r *http.Request) (int64, bool){
+	orgID, ok := r.Context().Value(organizationContextKey).(int64)
+	return orgID, ok
+}
+
+func (app *application) contextSetDelegated(r *http.Request) *http.Request{
+	ctx := context.WithValue(r.Context(), delegatedContextKey, true)
+	return r.WithContext(ctx)
+}
+
+// true when the request was made with a restricted credential, the
+// permissions in the context are then the only ones it can use
+func (app *application) contextIsDelegated(r *http.Request) bool{
+	delegated, _ := r.Context().Value(delegatedContextKey).(bool)
+	return delegated
+}
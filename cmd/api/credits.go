@@ -0,0 +1,220 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"greenlight/internal/data"
+	"greenlight/internal/policy"
+	"greenlight/internal/validator"
+)
+
+// load the movie of the :id parameter, the caller must be able to change it
+func (app *application) readWritableMovie(w http.ResponseWriter, r *http.Request) (*data.Movie, bool){
+	movie, ok := app.readMovieParam(w, r)
+	if !ok{
+		return nil, false
+	}
+
+	err := app.authorize(r, policy.MovieWrite, movie)
+	if err != nil{
+		switch{
+		case errors.Is(err, policy.ErrForbidden):
+			app.notPermittedResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+
+	return movie, true
+}
+
+// same for the :credit_id parameter of the movie
+func (app *application) readCredit(w http.ResponseWriter, r *http.Request) (*data.Credit, bool){
+	movie, ok := app.readWritableMovie(w, r)
+	if !ok{
+		return nil, false
+	}
+
+	id, err := app.readNamedIDParam(r, "credit_id")
+	if err != nil{
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	credit, err := app.models.Credits.Get(id, movie.ID)
+	if err != nil{
+		switch{
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+
+	return credit, true
+}
+
+func (app *application) listCreditsHandler(w http.ResponseWriter, r *http.Request){
+	movie, ok := app.readMovieParam(w, r)
+	if !ok{
+		return
+	}
+
+	credits, err := app.models.Credits.GetAllForMovie(movie.ID)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"credits": credits}, nil)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) createCreditHandler(w http.ResponseWriter, r *http.Request){
+	movie, ok := app.readWritableMovie(w, r)
+	if !ok{
+		return
+	}
+
+	var input struct{
+		PersonID int64 `json:"person_id"`
+		Role string `json:"role"`
+		Character string `json:"character"`
+		BillingOrder int `json:"billing_order"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil{
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	credit := &data.Credit{
+		MovieID: movie.ID,
+		PersonID: input.PersonID,
+		Role: input.Role,
+		Character: input.Character,
+		BillingOrder: input.BillingOrder,
+	}
+
+	v := validator.New()
+
+	if data.ValidateCredit(v, credit); !v.Valid(){
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	person, err := app.models.People.Get(credit.PersonID)
+	if err != nil{
+		switch{
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("person_id", "must be an existing person")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	credit.PersonName = person.Name
+
+	err = app.models.Credits.Insert(credit)
+	if err != nil{
+		switch{
+		case errors.Is(err, data.ErrDuplicateCredit):
+			v.AddError("person_id", "already credited for this role")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"credit": credit}, nil)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) updateCreditHandler(w http.ResponseWriter, r *http.Request){
+	credit, ok := app.readCredit(w, r)
+	if !ok{
+		return
+	}
+
+	var input struct{
+		Role *string `json:"role"`
+		Character *string `json:"character"`
+		BillingOrder *int `json:"billing_order"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil{
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.Role != nil{
+		credit.Role = *input.Role
+	}
+	if input.Character != nil{
+		credit.Character = *input.Character
+	}
+	if input.BillingOrder != nil{
+		credit.BillingOrder = *input.BillingOrder
+	}
+
+	v := validator.New()
+
+	if data.ValidateCredit(v, credit); !v.Valid(){
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Credits.Update(credit)
+	if err != nil{
+		switch{
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		case errors.Is(err, data.ErrDuplicateCredit):
+			v.AddError("person_id", "already credited for this role")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"credit": credit}, nil)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteCreditHandler(w http.ResponseWriter, r *http.Request){
+	credit, ok := app.readCredit(w, r)
+	if !ok{
+		return
+	}
+
+	err := app.models.Credits.Delete(credit.ID, credit.MovieID)
+	if err != nil{
+		switch{
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "credit successfully deleted"}, nil)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+	}
+}
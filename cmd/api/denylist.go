@@ -0,0 +1,165 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"greenlight/internal/data"
+	"greenlight/internal/jwt"
+)
+
+// jwtDenylist keep the revoked jwt in memory so authenticate
+// don't have to ask the database on every request
+type jwtDenylist struct{
+	mu sync.RWMutex
+	tokens map[string]time.Time
+	users map[int64]time.Time
+}
+
+func newJWTDenylist() *jwtDenylist{
+	return &jwtDenylist{
+		tokens: make(map[string]time.Time),
+		users: make(map[int64]time.Time),
+	}
+}
+
+func (d *jwtDenylist) add(token *data.DeniedToken){
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if token.JTI != ""{
+		d.tokens[token.JTI] = token.Expiry
+		return
+	}
+
+	// keep the latest revoke time for the user
+	if token.RevokedAt.After(d.users[token.UserID]){
+		d.users[token.UserID] = token.RevokedAt
+	}
+}
+
+func (d *jwtDenylist) revoked(claims *jwt.Claims) bool{
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if _, found := d.tokens[claims.ID]; found{
+		return true
+	}
+
+	revokedAt, found := d.users[claims.Subject]
+	if !found{
+		return false
+	}
+
+	// a token without iat_us only has whole seconds, one issued in the same
+	// second as the revoke can be from before it so it is denied
+	if claims.IssuedAtMicro == 0{
+		return claims.IssuedAt <= revokedAt.Unix()
+	}
+
+	return claims.IssuedAtMicro < revokedAt.UnixMicro()
+}
+
+// replace everything with the entry loaded from database, the new maps are
+// built first so there is no moment where a revoked token is accepted
+func (d *jwtDenylist) reset(tokens []*data.DeniedToken){
+	fresh := newJWTDenylist()
+	for _, token := range tokens{
+		fresh.add(token)
+	}
+
+	d.mu.Lock()
+	d.tokens = fresh.tokens
+	d.users = fresh.users
+	d.mu.Unlock()
+}
+
+// load the deny list from database now and then every interval, so
+// revoke done by other instance of the api are seen here too. the goroutine
+// is in app.wg and stop when app.denylistDone is closed
+func (app *application) syncDenylist(interval time.Duration) error{
+	load := func() error{
+		err := app.models.Denylist.DeleteExpired()
+		if err != nil{
+			return err
+		}
+
+		tokens, err := app.models.Denylist.GetAllActive()
+		if err != nil{
+			return err
+		}
+
+		app.denylist.reset(tokens)
+		return nil
+	}
+
+	err := load()
+	if err != nil{
+		return err
+	}
+
+	app.wg.Add(1)
+
+	go func(){
+		defer app.wg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for{
+			select{
+			case <-app.denylistDone:
+				return
+			case <-ticker.C:
+			}
+
+			err := load()
+			if err != nil{
+				app.logger.PrintError(err, nil)
+			}
+		}
+	}()
+
+	return nil
+}
+
+// revoke a single jwt until it expire
+func (app *application) denyJWT(claims *jwt.Claims) error{
+	token := &data.DeniedToken{
+		JTI: claims.ID,
+		UserID: claims.Subject,
+		Expiry: time.Unix(claims.Expiry, 0),
+	}
+
+	err := app.models.Denylist.Insert(token)
+	if err != nil{
+		return err
+	}
+
+	app.denylist.add(token)
+	return nil
+}
+
+// revoke every jwt of the user issued until now, nothing to do
+// when we are not in jwt mode
+func (app *application) denyAllJWTForUser(userID int64) error{
+	if app.config.auth.mode != authModeJWT{
+		return nil
+	}
+
+	now := time.Now()
+
+	token := &data.DeniedToken{
+		UserID: userID,
+		RevokedAt: now,
+		Expiry: now.Add(app.config.tokens.authenticationTTL),
+	}
+
+	err := app.models.Denylist.Insert(token)
+	if err != nil{
+		return err
+	}
+
+	app.denylist.add(token)
+	return nil
+}
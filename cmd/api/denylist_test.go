@@ -0,0 +1,79 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"greenlight/internal/data"
+	"greenlight/internal/jwt"
+)
+
+func TestJWTDenylistRevokeAll(t *testing.T){
+	revokedAt := time.Unix(1_700_000_000, 500_000_000)
+
+	d := newJWTDenylist()
+	d.reset([]*data.DeniedToken{{UserID: 1, RevokedAt: revokedAt}})
+
+	tests := []struct{
+		name string
+		issuedAt time.Time
+		want bool
+	}{
+		{"issued before", revokedAt.Add(-time.Second), true},
+		{"issued earlier in the same second", revokedAt.Add(-time.Millisecond), true},
+		{"issued later in the same second", revokedAt.Add(time.Millisecond), false},
+		{"issued after", revokedAt.Add(time.Second), false},
+	}
+
+	for _, tt := range tests{
+		t.Run(tt.name, func(t *testing.T){
+			claims := &jwt.Claims{Subject: 1, IssuedAt: tt.issuedAt.Unix(), IssuedAtMicro: tt.issuedAt.UnixMicro()}
+
+			got := d.revoked(claims)
+			if got != tt.want{
+				t.Errorf("got %v; want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// tokens issued before iat_us was added only have whole seconds, in
+// doubt the same second is denied
+func TestJWTDenylistRevokeAllWithoutMicroseconds(t *testing.T){
+	revokedAt := time.Unix(1_700_000_000, 500_000_000)
+
+	d := newJWTDenylist()
+	d.reset([]*data.DeniedToken{{UserID: 1, RevokedAt: revokedAt}})
+
+	tests := []struct{
+		name string
+		issuedAt int64
+		want bool
+	}{
+		{"issued before", revokedAt.Unix() - 1, true},
+		{"issued the same second", revokedAt.Unix(), true},
+		{"issued after", revokedAt.Unix() + 1, false},
+	}
+
+	for _, tt := range tests{
+		t.Run(tt.name, func(t *testing.T){
+			got := d.revoked(&jwt.Claims{Subject: 1, IssuedAt: tt.issuedAt})
+			if got != tt.want{
+				t.Errorf("got %v; want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJWTDenylistRevokeOne(t *testing.T){
+	d := newJWTDenylist()
+	d.reset([]*data.DeniedToken{{JTI: "revoked", UserID: 1}})
+
+	if !d.revoked(&jwt.Claims{ID: "revoked", Subject: 1}){
+		t.Errorf("revoked jti was accepted")
+	}
+
+	if d.revoked(&jwt.Claims{ID: "other", Subject: 1}){
+		t.Errorf("other jti was denied")
+	}
+}
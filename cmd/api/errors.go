@@ -3,6 +3,8 @@ package main
 import (
 	"fmt"
 	"net/http"
+	"strconv"
+	"time"
 )
 
 func (app *application) logError(r *http.Request, err error){
@@ -90,3 +92,56 @@ func (app *application) notPermittedResponse(w http.ResponseWriter, r *http.Requ
 	message := "your user account dosn't have the necessary permissions to access this resource"
 	app.errorResponse(w, r, http.StatusForbidden, message)
 }
+
+func (app *application) delegatedCredentialResponse(w http.ResponseWriter, r *http.Request){
+	message := "this endpoint can't be used with a restricted api key or oauth token, authenticate as the user"
+	app.errorResponse(w, r, http.StatusForbidden, message)
+}
+
+func (app *application) registrationClosedResponse(w http.ResponseWriter, r *http.Request){
+	message := "registration is closed on this server"
+	app.errorResponse(w, r, http.StatusForbidden, message)
+}
+
+func (app *application) refreshTokenReusedResponse(w http.ResponseWriter, r *http.Request){
+	message := "refresh token has already been used, the session has been revoked for your safety"
+	app.errorResponse(w, r, http.StatusUnauthorized, message)
+}
+
+func (app *application) loginBackoffResponse(w http.ResponseWriter, r *http.Request, retryAfter time.Duration){
+	w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())+1))
+	message := "too many failed login attempts, please wait before trying again"
+	app.errorResponse(w, r, http.StatusTooManyRequests, message)
+}
+
+func (app *application) accountLockedResponse(w http.ResponseWriter, r *http.Request, retryAfter time.Duration){
+	w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())+1))
+	message := "too many failed login attempts, login is temporarily locked"
+	app.errorResponse(w, r, http.StatusLocked, message)
+}
+
+func (app *application) watchlistItemExistsResponse(w http.ResponseWriter, r *http.Request){
+	message := "the movie is already in your watchlist, use PATCH /v1/users/me/watchlist/:id to change it"
+	app.errorResponse(w, r, http.StatusConflict, message)
+}
+
+func (app *application) exportThrottledResponse(w http.ResponseWriter, r *http.Request, retryAfter time.Duration){
+	w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())+1))
+	message := "a data export was requested recently, please wait before requesting a new one"
+	app.errorResponse(w, r, http.StatusTooManyRequests, message)
+}
+
+// oauth2 want the error code in "error" and the message in "error_description" (RFC 6749)
+func (app *application) oauthErrorResponse(w http.ResponseWriter, r *http.Request, status int, code, description string){
+	if status == http.StatusUnauthorized{
+		w.Header().Set("WWW-Authenticate", "Basic")
+	}
+
+	env := envelope{"error": code, "error_description": description}
+
+	err := app.writeJSON(w, status, env, http.Header{"Cache-Control": []string{"no-store"}})
+	if err != nil{
+		app.logError(r, err)
+		w.WriteHeader(500)
+	}
+}
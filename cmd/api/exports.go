@@ -0,0 +1,301 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"greenlight/internal/data"
+	"greenlight/internal/validator"
+)
+
+// how long the archive and the download token stay valid
+const exportTTL = 24 * time.Hour
+
+// building an archive read everything about the user, a new export is
+// refused while one is being built or if the last one is more recent than
+// exportInterval
+const (
+	exportInterval = time.Hour
+	exportPendingRetry = time.Minute
+)
+
+// the users with an export being built by this server
+type exportTracker struct{
+	mu sync.Mutex
+	pending map[int64]bool
+}
+
+func newExportTracker() *exportTracker{
+	return &exportTracker{pending: make(map[int64]bool)}
+}
+
+// mark the export of the user as pending, false if it already is
+func (t *exportTracker) start(userID int64) bool{
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.pending[userID]{
+		return false
+	}
+
+	t.pending[userID] = true
+	return true
+}
+
+func (t *exportTracker) finish(userID int64){
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.pending, userID)
+}
+
+// the archive is built in the background, the user get a download token by
+// mail once it is ready
+func (app *application) createUserExportHandler(w http.ResponseWriter, r *http.Request){
+	userID := app.contextGetUser(r).ID
+
+	if !app.exports.start(userID){
+		app.exportThrottledResponse(w, r, exportPendingRetry)
+		return
+	}
+
+	user, ok := app.currentUser(w, r)
+	if !ok{
+		app.exports.finish(userID)
+		return
+	}
+
+	createdAt, err := app.models.Exports.CreatedAt(user.ID)
+	if err != nil && !errors.Is(err, data.ErrRecordNotFound){
+		app.exports.finish(userID)
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if err == nil && time.Since(createdAt) < exportInterval{
+		app.exports.finish(userID)
+		app.exportThrottledResponse(w, r, time.Until(createdAt.Add(exportInterval)))
+		return
+	}
+
+	app.backgroud(func(){
+		defer app.exports.finish(userID)
+
+		err := app.exportUserData(user)
+		if err != nil{
+			app.logger.PrintError(err, map[string]string{"user_id": fmt.Sprint(user.ID)})
+		}
+	})
+
+	env := envelope{"message": "your data export is being prepared, you will receive an email when it is ready"}
+
+	err = app.writeJSON(w, http.StatusAccepted, env, nil)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) exportUserData(user *data.User) error{
+	archive, err := app.buildUserExport(user)
+	if err != nil{
+		return err
+	}
+
+	js, err := json.Marshal(archive)
+	if err != nil{
+		return err
+	}
+
+	err = app.models.Exports.Insert(user.ID, js, time.Now().Add(exportTTL))
+	if err != nil{
+		return err
+	}
+
+	// only the token of the last export can download it
+	err = app.models.Tokens.DeletAllForUser(data.ScopeDataExport, user.ID)
+	if err != nil{
+		return err
+	}
+
+	token, err := app.models.Tokens.New(user.ID, exportTTL, data.ScopeDataExport)
+	if err != nil{
+		return err
+	}
+
+	data := map[string]any{
+		"exportToken": token.Plaintext,
+	}
+
+	return app.mailer.Send(user.Email, "data_export_ready.tmpl", data)
+}
+
+// everything we keep about the user, the token hashes are left out
+func (app *application) buildUserExport(user *data.User) (envelope, error){
+	tokens := []envelope{}
+
+	scopes := []string{
+		data.ScopeActivation,
+		data.ScopeAuthentication,
+		data.ScopePasswordReset,
+		data.ScopeRefresh,
+		data.ScopeMFAPending,
+		data.ScopeMagicLink,
+		data.ScopeEmailChange,
+		data.ScopeDataExport,
+	}
+
+	for _, scope := range scopes{
+		scopeTokens, err := app.models.Tokens.GetAllForUser(scope, user.ID)
+		if err != nil{
+			return nil, err
+		}
+
+		for _, token := range scopeTokens{
+			tokens = append(tokens, envelope{
+				"scope": token.Scope,
+				"created_at": token.CreatedAt,
+				"last_used_at": token.LastUsedAt,
+				"expiry": token.Expiry,
+				"user_agent": token.UserAgent,
+				"ip": token.IP,
+			})
+		}
+	}
+
+	permissions, err := app.models.Permissions.GetAllForUser(user.ID)
+	if err != nil{
+		return nil, err
+	}
+
+	roles, err := app.models.Roles.GetAllForUser(user.ID)
+	if err != nil{
+		return nil, err
+	}
+
+	apiKeys, err := app.models.APIKeys.GetAllForUser(user.ID)
+	if err != nil{
+		return nil, err
+	}
+
+	clients, err := app.models.OAuthClients.GetAllForUser(user.ID)
+	if err != nil{
+		return nil, err
+	}
+
+	movies, err := app.models.Movies.GetAllCreatedBy(user.ID)
+	if err != nil{
+		return nil, err
+	}
+
+	reviews, err := app.models.Reviews.GetAllByUser(user.ID)
+	if err != nil{
+		return nil, err
+	}
+
+	watchlist, err := app.models.Watchlist.GetAllByUser(user.ID)
+	if err != nil{
+		return nil, err
+	}
+
+	lists := []envelope{}
+
+	userLists, err := app.models.Lists.GetAllForUser(user.ID)
+	if err != nil{
+		return nil, err
+	}
+
+	for _, list := range userLists{
+		items, err := app.models.Lists.GetAllItems(list.ID)
+		if err != nil{
+			return nil, err
+		}
+
+		lists = append(lists, envelope{"list": list, "items": items})
+	}
+
+	orgs, err := app.models.Organizations.GetAllForUser(user.ID)
+	if err != nil{
+		return nil, err
+	}
+
+	twoFactor, err := app.models.TwoFactor.Enabled(user.ID)
+	if err != nil{
+		return nil, err
+	}
+
+	revisions, err := app.models.Revisions.GetAllByEditor(user.ID)
+	if err != nil{
+		return nil, err
+	}
+
+	return envelope{
+		"generated_at": time.Now(),
+		"user": user,
+		"tokens": tokens,
+		"permissions": permissions,
+		"roles": roles,
+		"api_keys": apiKeys,
+		"oauth_clients": clients,
+		"movies": movies,
+		"reviews": reviews,
+		"watchlist": watchlist,
+		"lists": lists,
+		"organizations": orgs,
+		"two_factor_enabled": twoFactor,
+		"revisions": revisions,
+	}, nil
+}
+
+// the token is read from the body and not the query string so it don't end
+// up in the access logs and the browser history
+func (app *application) showUserExportHandler(w http.ResponseWriter, r *http.Request){
+	var input struct{
+		Token string `json:"token"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil{
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	if data.ValidateTokenPlaintext(v, input.Token); !v.Valid(){
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	user, err := app.models.Users.GetForToken(data.ScopeDataExport, input.Token)
+	if err != nil{
+		switch{
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("token", "invalid or expired data export token")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	archive, err := app.models.Exports.Get(user.ID)
+	if err != nil{
+		switch{
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	headers := http.Header{"Content-Disposition": []string{`attachment; filename="greenlight-export.json"`}}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"export": archive}, headers)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+	}
+}
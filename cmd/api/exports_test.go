@@ -0,0 +1,26 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+// a pending export is refused before the user is read so the request never
+// reach the mock models
+func TestExportRefusedWhilePending(t *testing.T){
+	app := newTestApplication(t)
+	token := newTestToken(t, app, 1)
+
+	if !app.exports.start(1){
+		t.Fatal("first export refused")
+	}
+
+	status := executeRequest(t, app, http.MethodPost, "/v1/users/me/export", token, "")
+	assertStatus(t, status, http.StatusTooManyRequests)
+
+	app.exports.finish(1)
+
+	if !app.exports.start(1){
+		t.Fatal("export refused after the pending one finished")
+	}
+}
@@ -8,8 +8,11 @@ import (
 	"strconv"
 	"fmt"
 	"io"
+	"net"
 	"strings"
 
+	"greenlight/internal/data"
+	"greenlight/internal/policy"
 	"greenlight/internal/validator"
 
 	"github.com/julienschmidt/httprouter"
@@ -29,6 +32,19 @@ func (app *application) readIDParam(r *http.Request) (int64, error){
 	return id, nil
 }
 
+// same as readIDParam for routes with a second id like /v1/movies/:id/reviews/:review_id
+func (app *application) readNamedIDParam(r *http.Request, name string) (int64, error){
+
+	params := httprouter.ParamsFromContext(r.Context())
+
+	id, err := strconv.ParseInt(params.ByName(name), 10, 64)
+	if err != nil || id < 1 {
+		return 0, fmt.Errorf("invalid %s parameter", name)
+	}
+
+	return id, nil
+}
+
 func (app *application) writeJSON(w http.ResponseWriter, status int, data envelope, headers http.Header) error{
 	js, err := json.MarshalIndent(data, "", "  ")
 	if err != nil{
@@ -165,6 +181,24 @@ func (app *application) readInt(qs url.Values, key string, defaultValue int, v *
 
 }
 
+// same as readInt but for true/false, nil mean the parameter was not given
+func (app *application) readBool(qs url.Values, key string, v *validator.Validator) *bool{
+
+	s := qs.Get(key)
+
+	if s == ""{
+		return nil
+	}
+
+	b, err := strconv.ParseBool(s)
+	if err != nil{
+		v.AddError(key, "must be a boolean value")
+		return nil
+	}
+
+	return &b
+}
+
 // we are using this function for any to execute any go routine function and panic handling if happen in it
 func (app *application) backgroud(fn func()){
 	// Increment the wait group counter
@@ -185,3 +219,44 @@ func (app *application) backgroud(fn func()){
 		fn()
 	}()
 }
+
+// return the ip address of the client without the port
+func (app *application) clientIP(r *http.Request) string{
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil{
+		return r.RemoteAddr
+	}
+	return ip
+}
+
+// check the policy for the user making the request, permissions come from the
+// context when requirePermission has loaded them already
+func (app *application) authorize(r *http.Request, p policy.Policy, resource policy.Resource) error{
+	user := app.contextGetUser(r)
+
+	permissions, ok := app.contextGetPermissions(r)
+	if !ok{
+		var err error
+		permissions, err = app.models.Permissions.GetEffectiveForUser(user.ID)
+		if err != nil{
+			return err
+		}
+	}
+
+	actor := policy.Actor{UserID: user.ID, Permissions: permissions}
+
+	// the role is only needed in the organization of the resource
+	if orgResource, ok := resource.(policy.OrgResource); ok{
+		if orgID, ok := orgResource.OrganizationID(); ok{
+			member, err := app.models.Organizations.GetMember(orgID, user.ID)
+			switch{
+			case err == nil:
+				actor.OrgRoles = map[int64]string{orgID: member.Role}
+			case !errors.Is(err, data.ErrRecordNotFound):
+				return err
+			}
+		}
+	}
+
+	return p.Authorize(actor, resource)
+}
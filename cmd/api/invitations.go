@@ -0,0 +1,75 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"greenlight/internal/data"
+	"greenlight/internal/validator"
+)
+
+// how long an invited user has to register
+const invitationTTL = 7 * 24 * time.Hour
+
+func (app *application) createInvitationHandler(w http.ResponseWriter, r *http.Request){
+	var input struct{
+		Email string `json:"email"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil{
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	if data.ValidateEmail(v, input.Email); !v.Valid(){
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	_, err = app.models.Users.GetByEmail(input.Email)
+	switch{
+	case err == nil:
+		v.AddError("email", "a user with this email address already exists")
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	case !errors.Is(err, data.ErrRecordNotFound):
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	admin := app.contextGetUser(r)
+
+	invitation := &data.Invitation{
+		Email: input.Email,
+		InvitedBy: &admin.ID,
+	}
+
+	// the target id is set by the insert
+	entry := app.auditEntry(r, "invitation.created", "invitation", 0, map[string]any{"email": invitation.Email})
+
+	err = app.models.Invitations.Insert(invitation, invitationTTL, entry)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.backgroud(func(){
+		data := map[string]any{
+			"invitationToken": invitation.Plaintext,
+		}
+
+		err = app.mailer.Send(invitation.Email, "invitation.tmpl", data)
+		if err != nil{
+			app.logger.PrintError(err, nil)
+		}
+	})
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"invitation": invitation}, nil)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+	}
+}
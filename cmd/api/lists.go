@@ -0,0 +1,328 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"greenlight/internal/data"
+	"greenlight/internal/policy"
+	"greenlight/internal/validator"
+)
+
+// load the list of the :id parameter, a private list is not found for
+// anyone who can't change it. when ok is false the response was already sent
+func (app *application) readList(w http.ResponseWriter, r *http.Request) (*data.List, bool){
+	id, err := app.readIDParam(r)
+	if err != nil{
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	list, err := app.models.Lists.Get(id)
+	if err != nil{
+		switch{
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+
+	if list.Public{
+		return list, true
+	}
+
+	err = app.authorize(r, policy.ListWrite, list)
+	if err != nil{
+		switch{
+		case errors.Is(err, policy.ErrForbidden):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+
+	return list, true
+}
+
+// same as readList but the caller must be able to change the list
+func (app *application) readWritableList(w http.ResponseWriter, r *http.Request) (*data.List, bool){
+	list, ok := app.readList(w, r)
+	if !ok{
+		return nil, false
+	}
+
+	err := app.authorize(r, policy.ListWrite, list)
+	if err != nil{
+		switch{
+		case errors.Is(err, policy.ErrForbidden):
+			app.notPermittedResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+
+	return list, true
+}
+
+func (app *application) createListHandler(w http.ResponseWriter, r *http.Request){
+	user := app.contextGetUser(r)
+
+	var input struct{
+		Name string `json:"name"`
+		Description string `json:"description"`
+		Public bool `json:"public"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil{
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	list := &data.List{
+		UserID: user.ID,
+		Name: input.Name,
+		Description: input.Description,
+		Public: input.Public,
+	}
+
+	v := validator.New()
+
+	if data.ValidateList(v, list); !v.Valid(){
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Lists.Insert(list)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/lists/%d", list.ID))
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"list": list}, headers)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) listListsHandler(w http.ResponseWriter, r *http.Request){
+	user := app.contextGetUser(r)
+
+	lists, err := app.models.Lists.GetAllForUser(user.ID)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"lists": lists}, nil)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) showListHandler(w http.ResponseWriter, r *http.Request){
+	list, ok := app.readList(w, r)
+	if !ok{
+		return
+	}
+
+	var input struct{
+		data.Filters
+	}
+
+	v := validator.New()
+
+	qs := r.URL.Query()
+
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+
+	// items are always in the order of the list
+	input.Filters.Sort = "position"
+	input.Filters.SortSafelist = []string{"position"}
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid(){
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	// a public list don't show the private movies of other organizations
+	orgID, ok := app.activeOrganization(w, r)
+	if !ok{
+		return
+	}
+
+	items, metadata, err := app.models.Lists.GetItems(list.ID, orgID, input.Filters)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"list": list, "items": items, "metadata": metadata}, nil)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) updateListHandler(w http.ResponseWriter, r *http.Request){
+	list, ok := app.readWritableList(w, r)
+	if !ok{
+		return
+	}
+
+	var input struct{
+		Name *string `json:"name"`
+		Description *string `json:"description"`
+		Public *bool `json:"public"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil{
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.Name != nil{
+		list.Name = *input.Name
+	}
+	if input.Description != nil{
+		list.Description = *input.Description
+	}
+	if input.Public != nil{
+		list.Public = *input.Public
+	}
+
+	v := validator.New()
+
+	if data.ValidateList(v, list); !v.Valid(){
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Lists.Update(list)
+	if err != nil{
+		switch{
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"list": list}, nil)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteListHandler(w http.ResponseWriter, r *http.Request){
+	list, ok := app.readWritableList(w, r)
+	if !ok{
+		return
+	}
+
+	err := app.models.Lists.Delete(list.ID)
+	if err != nil{
+		switch{
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "list successfully deleted"}, nil)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// add a movie to the list or move it when it is already there
+func (app *application) setListItemHandler(w http.ResponseWriter, r *http.Request){
+	list, ok := app.readWritableList(w, r)
+	if !ok{
+		return
+	}
+
+	var input struct{
+		MovieID int64 `json:"movie_id"`
+		Position *int `json:"position"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil{
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	if input.Position != nil{
+		if v.Check(*input.Position >= 1, "position", "must be at least 1"); !v.Valid(){
+			app.failedValidationResponse(w, r, v.Errors)
+			return
+		}
+	}
+
+	movie, ok := app.readMovieInput(w, r, v, input.MovieID)
+	if !ok{
+		return
+	}
+
+	// the movie was read in the active organization, so its org is the one
+	// the positions are counted in
+	position, err := app.models.Lists.SetItem(list.ID, movie.ID, movie.OrgID, input.Position)
+	if err != nil{
+		switch{
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"item": envelope{"position": position, "movie": movie}}, nil)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteListItemHandler(w http.ResponseWriter, r *http.Request){
+	list, ok := app.readWritableList(w, r)
+	if !ok{
+		return
+	}
+
+	movieID, err := app.readNamedIDParam(r, "movie_id")
+	if err != nil{
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.models.Lists.RemoveItem(list.ID, movieID)
+	if err != nil{
+		switch{
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "movie successfully removed from the list"}, nil)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+	}
+}
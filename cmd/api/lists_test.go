@@ -0,0 +1,57 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestListRoutesRequireAuthentication(t *testing.T){
+	app := newTestApplication(t)
+
+	tests := []struct{
+		name string
+		method string
+		path string
+	}{
+		{"list", http.MethodGet, "/v1/lists"},
+		{"create", http.MethodPost, "/v1/lists"},
+		{"show", http.MethodGet, "/v1/lists/1"},
+		{"update", http.MethodPatch, "/v1/lists/1"},
+		{"delete", http.MethodDelete, "/v1/lists/1"},
+		{"set item", http.MethodPut, "/v1/lists/1/items"},
+		{"remove item", http.MethodDelete, "/v1/lists/1/items/1"},
+		{"watchlist", http.MethodGet, "/v1/users/me/watchlist"},
+		{"add to watchlist", http.MethodPost, "/v1/users/me/watchlist"},
+	}
+
+	for _, tt := range tests{
+		t.Run(tt.name, func(t *testing.T){
+			status := executeRequest(t, app, tt.method, tt.path, "", `{}`)
+			assertStatus(t, status, http.StatusUnauthorized)
+		})
+	}
+}
+
+// adding a movie read it from the catalog so it need the read permission
+func TestListItemRoutesRequireReadPermission(t *testing.T){
+	app := newTestApplication(t)
+	token := newTestToken(t, app, 1)
+
+	body := `{"movie_id": 1}`
+
+	tests := []struct{
+		name string
+		method string
+		path string
+	}{
+		{"set list item", http.MethodPut, "/v1/lists/1/items"},
+		{"add to watchlist", http.MethodPost, "/v1/users/me/watchlist"},
+	}
+
+	for _, tt := range tests{
+		t.Run(tt.name, func(t *testing.T){
+			status := executeRequest(t, app, tt.method, tt.path, token, body)
+			assertStatus(t, status, http.StatusForbidden)
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"greenlight/internal/data"
+)
+
+// check if a login for the email can be tried now from the ip, it return the
+// status code to answer with (0 when the login is allowed) and the wait time
+func (app *application) loginThrottle(email, ip string) (int, time.Duration, error){
+	now := time.Now()
+
+	ipFailure, err := app.models.LoginFailures.Get(data.LoginFailureIP, ip)
+	if err != nil && !errors.Is(err, data.ErrRecordNotFound){
+		return 0, 0, err
+	}
+	if ipFailure != nil && ipFailure.Locked(now){
+		return http.StatusLocked, ipFailure.LockedUntil.Sub(now), nil
+	}
+
+	accountFailure, err := app.models.LoginFailures.Get(data.LoginFailureAccount, email)
+	if err != nil{
+		switch{
+		case errors.Is(err, data.ErrRecordNotFound):
+			return 0, 0, nil
+		default:
+			return 0, 0, err
+		}
+	}
+
+	if accountFailure.Locked(now){
+		return http.StatusLocked, accountFailure.LockedUntil.Sub(now), nil
+	}
+
+	// after a few failure every new try has to wait twice as long as the last one
+	if accountFailure.Failures >= app.config.login.backoffAfter{
+		delay := app.config.login.lockoutDuration
+		if shift := accountFailure.Failures - app.config.login.backoffAfter; shift < 30 && time.Second<<shift < delay{
+			delay = time.Second << shift
+		}
+
+		wait := accountFailure.LastFailureAt.Add(delay).Sub(now)
+		if wait > 0{
+			return http.StatusTooManyRequests, wait, nil
+		}
+	}
+
+	return 0, 0, nil
+}
+
+// count a failed login for the email and the ip and lock them when they reach
+// the limit, user is nil when no account has this email
+func (app *application) recordLoginFailure(email, ip string, user *data.User) error{
+	now := time.Now()
+	lockedUntil := now.Add(app.config.login.lockoutDuration)
+
+	ipFailure, err := app.models.LoginFailures.RecordFailure(data.LoginFailureIP, ip, app.config.login.lockoutDuration)
+	if err != nil{
+		return err
+	}
+
+	if ipFailure.Failures >= app.config.login.ipLockoutAfter && !ipFailure.Locked(now){
+		err = app.models.LoginFailures.Lock(data.LoginFailureIP, ip, lockedUntil)
+		if err != nil{
+			return err
+		}
+
+		app.logger.PrintInfo("ip address locked after failed logins", map[string]string{
+			"ip": ip,
+		})
+	}
+
+	accountFailure, err := app.models.LoginFailures.RecordFailure(data.LoginFailureAccount, email, app.config.login.lockoutDuration)
+	if err != nil{
+		return err
+	}
+
+	if accountFailure.Failures < app.config.login.lockoutAfter || accountFailure.Locked(now){
+		return nil
+	}
+
+	err = app.models.LoginFailures.Lock(data.LoginFailureAccount, email, lockedUntil)
+	if err != nil{
+		return err
+	}
+
+	// let the owner know, it may be someone trying to get in
+	if user != nil{
+		app.backgroud(func(){
+			data := map[string]any{
+				"lockedUntil": lockedUntil.UTC().Format(time.RFC1123),
+				"ip": ip,
+			}
+
+			err := app.mailer.Send(user.Email, "account_locked.tmpl", data)
+			if err != nil{
+				app.logger.PrintError(err, nil)
+			}
+		})
+	}
+
+	return nil
+}
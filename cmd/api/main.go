@@ -4,19 +4,36 @@ import (
 	"context"
 	"database/sql"
 	"flag"
+	"fmt"
 	"os"
+	"strings"
 	"time"
 	"sync"
 
 	"greenlight/internal/data"
 	"greenlight/internal/jsonlog"
+	"greenlight/internal/jwt"
 	"greenlight/internal/mailer"
 
 	_ "github.com/lib/pq"
+	"golang.org/x/time/rate"
 )
 
 const version = "1.0.0"
 
+// the way authentication token are issued and checked
+const (
+	authModeDatabase = "database"
+	authModeJWT = "jwt"
+)
+
+// who can create an account with POST /v1/users
+const (
+	registrationOpen = "open"
+	registrationInvite = "invite"
+	registrationClosed = "closed"
+)
+
 type config struct{
 	port int
 	env string
@@ -30,6 +47,9 @@ type config struct{
 		rps float64
 		burst int
 		enabled bool
+		// per email limit of the endpoints sending mails, separate from the
+		// ip limiter so turning that one off keep the spam protection
+		emailEnabled bool
 	}
 	smtp struct{
 		host string
@@ -38,6 +58,28 @@ type config struct{
 		password string
 		sender string
 	}
+	tokens struct{
+		authenticationTTL time.Duration
+		refreshTTL time.Duration
+	}
+	auth struct{
+		mode string
+		jwtKeys string
+		jwtSigningKID string
+	}
+	login struct{
+		backoffAfter int
+		lockoutAfter int
+		ipLockoutAfter int
+		lockoutDuration time.Duration
+	}
+	trash struct{
+		retention time.Duration
+		sweepInterval time.Duration
+	}
+	// permission codes given to a user when the account is activated
+	defaultPermissions []string
+	registrationMode string
 }
 
 type application struct{
@@ -46,6 +88,15 @@ type application struct{
 	models data.Models
 	mailer mailer.Mailer
 	wg sync.WaitGroup
+	activationLimiter *keyRateLimiter
+	magicLinkLimiter *keyRateLimiter
+	passwordResetLimiter *keyRateLimiter
+	exports *exportTracker
+	jwtKeys *jwt.KeySet
+	denylist *jwtDenylist
+	// closed on shutdown to stop the trash sweeper and the deny list sync
+	sweeperDone chan struct{}
+	denylistDone chan struct{}
 }
 
 
@@ -64,6 +115,7 @@ func main(){
 	flag.Float64Var(&cfg.limiter.rps, "limiter-rps", 2, "Rate limiter maximum request per second")
 	flag.IntVar(&cfg.limiter.burst, "limiter-burst", 4, "Rate limiter maximum burst")
 	flag.BoolVar(&cfg.limiter.enabled, "limiter-enabled", true, "Enable rate limiter")
+	flag.BoolVar(&cfg.limiter.emailEnabled, "limiter-email-enabled", true, "Enable the per email rate limiter of endpoints sending mails")
 	
 	// mailer config
 	flag.StringVar(&cfg.smtp.host, "smtp-host", "smtp.mailtrap.io", "SMTP host")
@@ -72,6 +124,36 @@ func main(){
 	flag.StringVar(&cfg.smtp.password, "smtp-password", "c3e8322dc99e4a", "SMTP password")
 	flag.StringVar(&cfg.smtp.sender, "smtp-sender", "Greenlight <no-reply@greenligh.net>", "SMTP sender")
 
+	flag.DurationVar(&cfg.tokens.authenticationTTL, "token-authentication-ttl", time.Hour, "Authentication token lifetime")
+	flag.DurationVar(&cfg.tokens.refreshTTL, "token-refresh-ttl", 30*24*time.Hour, "Refresh token lifetime")
+
+	flag.StringVar(&cfg.auth.mode, "auth-mode", authModeDatabase, "Authentication token mode (database|jwt)")
+	flag.StringVar(&cfg.auth.jwtKeys, "jwt-keys", os.Getenv("GREENLIGHT_JWT_KEYS"), "JWT keys as comma seperated kid:alg:base64key (alg is HS256 or EdDSA)")
+	flag.StringVar(&cfg.auth.jwtSigningKID, "jwt-signing-kid", "", "Key id used to sign new JWT")
+
+	flag.IntVar(&cfg.login.backoffAfter, "login-backoff-after", 3, "Failed logins for an account before exponential backoff start")
+	flag.IntVar(&cfg.login.lockoutAfter, "login-lockout-after", 10, "Failed logins for an account before it is locked")
+	flag.IntVar(&cfg.login.ipLockoutAfter, "login-ip-lockout-after", 50, "Failed logins from an IP address before it is locked")
+	flag.DurationVar(&cfg.login.lockoutDuration, "login-lockout-duration", 15*time.Minute, "How long a locked account or IP address stay locked")
+
+	cfg.defaultPermissions = []string{"movies:read"}
+	flag.Func("default-permissions", "Comma seperated permission codes given to newly activated users (default \"movies:read\")", func(val string) error{
+		cfg.defaultPermissions = []string{}
+		seen := make(map[string]bool)
+		for _, code := range strings.Split(val, ","){
+			if code = strings.TrimSpace(code); code != "" && !seen[code]{
+				seen[code] = true
+				cfg.defaultPermissions = append(cfg.defaultPermissions, code)
+			}
+		}
+		return nil
+	})
+
+	flag.StringVar(&cfg.registrationMode, "registration-mode", registrationOpen, "Who can register (open|invite|closed)")
+
+	flag.DurationVar(&cfg.trash.retention, "trash-retention", 30*24*time.Hour, "How long deleted movies stay in the trash before being purged (0 to keep them)")
+	flag.DurationVar(&cfg.trash.sweepInterval, "trash-sweep-interval", time.Hour, "How often the trash is swept")
+
 	flag.Parse()
 
 	logger :=  jsonlog.New(os.Stdout, jsonlog.LevelInfo)
@@ -89,6 +171,56 @@ func main(){
 		logger: logger,
 		models: data.NewModels(db),
 		mailer: mailer.New(cfg.smtp.host, cfg.smtp.port, cfg.smtp.username, cfg.smtp.password, cfg.smtp.sender),
+		// one activation email every 5 min for a address with a burst of 2
+		activationLimiter: newKeyRateLimiter(rate.Every(5*time.Minute), 2),
+		// same for login links
+		magicLinkLimiter: newKeyRateLimiter(rate.Every(5*time.Minute), 2),
+		// and for password reset emails
+		passwordResetLimiter: newKeyRateLimiter(rate.Every(5*time.Minute), 2),
+		exports: newExportTracker(),
+		denylist: newJWTDenylist(),
+		sweeperDone: make(chan struct{}),
+		denylistDone: make(chan struct{}),
+	}
+
+	if len(cfg.defaultPermissions) > 0{
+		unknown, err := app.models.Permissions.Unknown(cfg.defaultPermissions...)
+		if err != nil{
+			logger.PrintFatal(err, nil)
+		}
+		if len(unknown) > 0{
+			logger.PrintFatal(fmt.Errorf("unknown default permissions %s", strings.Join(unknown, ", ")), nil)
+		}
+	}
+
+	switch cfg.registrationMode{
+	case registrationOpen, registrationInvite, registrationClosed:
+	default:
+		logger.PrintFatal(fmt.Errorf("invalid registration mode %q", cfg.registrationMode), nil)
+	}
+
+	switch cfg.auth.mode{
+	case authModeDatabase:
+	case authModeJWT:
+		app.jwtKeys, err = jwt.ParseKeySet(cfg.auth.jwtKeys, cfg.auth.jwtSigningKID)
+		if err != nil{
+			logger.PrintFatal(err, nil)
+		}
+
+		err = app.syncDenylist(30*time.Second)
+		if err != nil{
+			logger.PrintFatal(err, nil)
+		}
+	default:
+		logger.PrintFatal(fmt.Errorf("invalid auth mode %q", cfg.auth.mode), nil)
+	}
+
+	if cfg.trash.sweepInterval <= 0{
+		logger.PrintFatal(fmt.Errorf("invalid trash sweep interval %s", cfg.trash.sweepInterval), nil)
+	}
+
+	if cfg.trash.retention > 0{
+		app.sweepTrash(cfg.trash.sweepInterval, app.models.Movies.PurgeDeletedBefore)
 	}
 
 	err = app.serve()
@@ -12,6 +12,7 @@ import (
 	"golang.org/x/time/rate"
 
 	"greenlight/internal/data"
+	"greenlight/internal/jwt"
 	"greenlight/internal/validator"
 )
 
@@ -91,20 +92,94 @@ func (app *application) rateLimit(next http.Handler)http.Handler{
 		})
 }
 
+// keyRateLimiter is like the rateLimit middleware but the bucket is picked by any key
+// we want (like an email address) so handler can use it after reading the body
+type keyRateLimiter struct{
+	mu sync.Mutex
+	limit rate.Limit
+	burst int
+	clients map[string]*keyClient
+	done chan struct{}
+}
+
+type keyClient struct{
+	limiter *rate.Limiter
+	lastSeen time.Time
+}
+
+func newKeyRateLimiter(limit rate.Limit, burst int) *keyRateLimiter{
+	l := &keyRateLimiter{
+		limit: limit,
+		burst: burst,
+		clients: make(map[string]*keyClient),
+		done: make(chan struct{}),
+	}
+
+	// same clean up as rateLimit but we keep client longer because
+	// the limit here is much slower, it run until stop is called
+	go func(){
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+
+		for{
+			select{
+			case <-l.done:
+				return
+			case <-ticker.C:
+			}
+
+			l.mu.Lock()
+			for key, client := range l.clients{
+				if time.Since(client.lastSeen) > time.Hour{
+					delete(l.clients, key)
+				}
+			}
+			l.mu.Unlock()
+		}
+	}()
+
+	return l
+}
+
+// stop the clean up goroutine, the limiter must not be used after
+func (l *keyRateLimiter) stop(){
+	close(l.done)
+}
+
+func (l *keyRateLimiter) allow(key string) bool{
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, found := l.clients[key]; !found{
+		l.clients[key] = &keyClient{limiter: rate.NewLimiter(l.limit, l.burst)}
+	}
+
+	l.clients[key].lastSeen = time.Now()
+
+	return l.clients[key].limiter.Allow()
+}
+
 func (app *application) authenticate(next http.Handler) http.Handler{
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request){
 		// Add the "vary authorization header to the response"
 		w.Header().Add("Vary", "Authorization")
+		w.Header().Add("Vary", "X-API-Key")
 
 		authorizationHeader := r.Header.Get("Authorization")
+		apiKey := r.Header.Get("X-API-Key")
 
 		// if no authorization found we make anonymous user add to the request
-		if authorizationHeader == ""{
+		if authorizationHeader == "" && apiKey == ""{
 			r = app.contextSetUser(r, data.AnonymousUser)
 			next.ServeHTTP(w, r)
 			return
 		}
 
+		if apiKey != ""{
+			app.authenticateAPIKey(w, r, next, apiKey)
+			return
+		}
+
 		headerParts := strings.Split(authorizationHeader, " ")
 		if len(headerParts) != 2 || headerParts[0] != "Bearer"{
 			app.invalidAuthenticationTokenResponse(w, r)
@@ -112,6 +187,37 @@ func (app *application) authenticate(next http.Handler) http.Handler{
 		}
 
 		token := headerParts[1]
+
+		// api key can also be sent as a bearer token, they have there own prefix
+		if data.IsAPIKey(token){
+			app.authenticateAPIKey(w, r, next, token)
+			return
+		}
+
+		// signed token carry everything we need so no database query here
+		if app.config.auth.mode == authModeJWT && jwt.LooksLikeJWT(token){
+			claims, err := app.jwtKeys.Verify(token, time.Now())
+			if err != nil || app.denylist.revoked(claims){
+				app.invalidAuthenticationTokenResponse(w, r)
+				return
+			}
+
+			user := &data.User{
+				ID: claims.Subject,
+				Activated: claims.Activated,
+			}
+
+			r = app.contextSetUser(r, user)
+			r = app.contextSetToken(r, token)
+			r = app.contextSetPermissions(r, data.Permissions(claims.Permissions))
+			if claims.Org != nil{
+				r = app.contextSetOrganization(r, *claims.Org)
+			}
+
+			next.ServeHTTP(w, r)
+			return
+		}
+
 		v := validator.New()
 
 		if data.ValidateTokenPlaintext(v, token); !v.Valid(){
@@ -130,12 +236,83 @@ func (app *application) authenticate(next http.Handler) http.Handler{
 			return
 		}
 
+		tokenInfo, err := app.models.Tokens.UpdateLastUsed(data.ScopeAuthentication, token)
+		if err != nil{
+			switch{
+			case errors.Is(err, data.ErrRecordNotFound):
+				app.invalidAuthenticationTokenResponse(w, r)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+
 		r = app.contextSetUser(r, user)
+		r = app.contextSetToken(r, token)
+
+		// token given to a oauth client only have the scopes the user agreed to
+		if tokenInfo.Permissions != nil{
+			permissions, err := app.models.Permissions.GetEffectiveForUser(user.ID)
+			if err != nil{
+				app.serverErrorResponse(w, r, err)
+				return
+			}
+			r = app.contextSetPermissions(r, permissions.Restrict(tokenInfo.Permissions))
+			r = app.contextSetDelegated(r)
+		}
+
+		if tokenInfo.OrgID != nil{
+			r = app.contextSetOrganization(r, *tokenInfo.OrgID)
+		}
 
 		next.ServeHTTP(w, r)
 		})
 }
 
+// authenticate the request with a api key, when the key has a permission
+// subset we put it in the context so requirePermission only see those
+func (app *application) authenticateAPIKey(w http.ResponseWriter, r *http.Request, next http.Handler, plaintext string){
+	v := validator.New()
+
+	if data.ValidateAPIKeyPlaintext(v, plaintext); !v.Valid(){
+		app.invalidAuthenticationTokenResponse(w, r)
+		return
+	}
+
+	key, user, err := app.models.APIKeys.GetForPlaintext(plaintext)
+	if err != nil{
+		switch{
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.invalidAuthenticationTokenResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.models.APIKeys.UpdateLastUsed(key.ID)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	r = app.contextSetUser(r, user)
+
+	if len(key.Permissions) > 0{
+		permissions, err := app.models.Permissions.GetEffectiveForUser(user.ID)
+		if err != nil{
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		// the key can't do more than the user, even if the user lost a permission after
+		r = app.contextSetPermissions(r, permissions.Restrict(key.Permissions))
+		r = app.contextSetDelegated(r)
+	}
+
+	next.ServeHTTP(w, r)
+}
+
 func (app *application) requireAuthenticatedUser(next http.HandlerFunc) http.HandlerFunc{
 
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request){
@@ -165,18 +342,38 @@ func (app *application) requireActivatedUser(next http.HandlerFunc) http.Handler
 
 	return app.requireAuthenticatedUser(fn)
 }
+// refuse restricted credentials on routes managing credentials or the account,
+// they would let a api key or oauth token get out of its permissions. it is
+// used inside requireAuthenticatedUser or requireActivatedUser
+func (app *application) requireUserCredential(next http.HandlerFunc) http.HandlerFunc{
+	return func(w http.ResponseWriter, r *http.Request){
+		if app.contextIsDelegated(r){
+			app.delegatedCredentialResponse(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	}
+}
+
 func (app *application) requirePermission(code string, next http.HandlerFunc) http.HandlerFunc{
 	fn := func(w http.ResponseWriter, r *http.Request){
 		// Retrieve the user from the request context
 		user := app.contextGetUser(r)
 
-		permissions, err := app.models.Permissions.GetAllForUser(user.ID)
-		if err != nil{
-			app.serverErrorResponse(w, r, err)
-			return
+		// jwt already carry the permissions of the user, otherwise we query
+		// them once and keep them in the context for the rest of the request
+		permissions, ok := app.contextGetPermissions(r)
+		if !ok{
+			var err error
+			permissions, err = app.models.Permissions.GetEffectiveForUser(user.ID)
+			if err != nil{
+				app.serverErrorResponse(w, r, err)
+				return
+			}
+			r = app.contextSetPermissions(r, permissions)
 		}
 
-		if !permissions.Include(code){
+		if !permissions.Allows(code){
 			app.notPermittedResponse(w, r)
 			return
 		}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"greenlight/internal/data"
+)
+
+func TestRequireUserCredential(t *testing.T){
+	app := newTestApplication(t)
+
+	next := func(w http.ResponseWriter, r *http.Request){
+		w.WriteHeader(http.StatusOK)
+	}
+
+	tests := []struct{
+		name string
+		delegated bool
+		want int
+	}{
+		{"user credential", false, http.StatusOK},
+		{"restricted credential", true, http.StatusForbidden},
+	}
+
+	for _, tt := range tests{
+		t.Run(tt.name, func(t *testing.T){
+			req := httptest.NewRequest(http.MethodPost, "/v1/users/me/api-keys", nil)
+			req = app.contextSetUser(req, &data.User{ID: 1, Activated: true})
+			if tt.delegated{
+				req = app.contextSetDelegated(req)
+			}
+
+			rr := httptest.NewRecorder()
+			app.requireActivatedUser(app.requireUserCredential(next)).ServeHTTP(rr, req)
+
+			assertStatus(t, rr.Code, tt.want)
+		})
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"strconv"
 
 	"greenlight/internal/data"
+	"greenlight/internal/policy"
 	"greenlight/internal/validator"
 )
 
@@ -25,11 +26,20 @@ func (app *application) createMovieHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	user := app.contextGetUser(r)
+
+	orgID, ok := app.activeOrganization(w, r)
+	if !ok{
+		return
+	}
+
 	movie := &data.Movie{
 		Title: input.Title,
 		Year: input.Year,
 		Runtime: input.Runtime,
 		Genres: input.Genres,
+		CreatedBy: &user.ID,
+		OrgID: orgID,
 	}
 
 	// returing empty validator struct
@@ -65,7 +75,12 @@ func (app *application) showMovieHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 	
-	movie, err := app.models.Movies.Get(id)
+	orgID, ok := app.activeOrganization(w, r)
+	if !ok{
+		return
+	}
+
+	movie, err := app.models.Movies.Get(id, orgID)
 	if err != nil{
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -90,7 +105,12 @@ func (app *application) updateMovieHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 	
-	movie, err := app.models.Movies.Get(id)
+	orgID, ok := app.activeOrganization(w, r)
+	if !ok{
+		return
+	}
+
+	movie, err := app.models.Movies.Get(id, orgID)
 	if err != nil{
 		switch{
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -101,11 +121,22 @@ func (app *application) updateMovieHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	err = app.authorize(r, policy.MovieWrite, movie)
+	if err != nil{
+		switch{
+		case errors.Is(err, policy.ErrForbidden):
+			app.notPermittedResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
 	// If the request contains a X-Expected-version header 
 	// verify that the movie version in the database
 	// matches the expected version specified in the headerc
 	if r.Header.Get("X-Expected-Version") != ""{
-		if strconv.FormatInt(int64(movie.Version), 32) != r.Header.Get("X-Expected-Version") {
+		if strconv.FormatInt(int64(movie.Version), 10) != r.Header.Get("X-Expected-Version") {
 			app.editConflictResponse(w, r)
 			return
 		}
@@ -133,7 +164,7 @@ func (app *application) updateMovieHandler(w http.ResponseWriter, r *http.Reques
 	if input.Runtime != nil{
 		movie.Runtime= *input.Runtime
 	}
-	if input.Title != nil{
+	if input.Genres != nil{
 		movie.Genres = input.Genres
 	}
 	
@@ -143,7 +174,7 @@ func (app *application) updateMovieHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 	
-	err = app.models.Movies.Update(movie)
+	err = app.models.Movies.Update(movie, app.contextGetUser(r).ID)
 	if err != nil{
 		switch{
 		case errors.Is(err, data.ErrEditConflict):
@@ -169,7 +200,34 @@ func (app *application) deleteMovieHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	err = app.models.Movies.Delete(id)
+	orgID, ok := app.activeOrganization(w, r)
+	if !ok{
+		return
+	}
+
+	movie, err := app.models.Movies.Get(id, orgID)
+	if err != nil{
+		switch{
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.authorize(r, policy.MovieWrite, movie)
+	if err != nil{
+		switch{
+		case errors.Is(err, policy.ErrForbidden):
+			app.notPermittedResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.models.Movies.Delete(id, orgID)
 	if err != nil{
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -180,7 +238,7 @@ func (app *application) deleteMovieHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	err = app.writeJSON(w, http.StatusOK, envelope{"message": "movie successfully deletd"}, nil)
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "movie successfully moved to the trash"}, nil)
 	if err != nil{
 		app.serverErrorResponse(w, r, err)
 	}
@@ -190,6 +248,8 @@ func (app *application) listMovieHandler(w http.ResponseWriter, r *http.Request)
 	var input struct{
 		Title string
 		Genres []string
+		Director string
+		Actor string
 		data.Filters
 	}
 
@@ -199,19 +259,26 @@ func (app *application) listMovieHandler(w http.ResponseWriter, r *http.Request)
 
 	input.Title = app.readStirng(qs, "title", "")
 	input.Genres = app.readCSV(qs, "genres", []string{})
+	input.Director = app.readStirng(qs, "director", "")
+	input.Actor = app.readStirng(qs, "actor", "")
 
 	input.Filters.Page = app.readInt(qs, "page", 1, v)
 	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
 
 	input.Filters.Sort = app.readStirng(qs, "sort", "id")
-	input.Filters.SortSafelist = []string{"id", "title", "year", "runtime", "-id", "-title", "-year", "-runtime"}
+	input.Filters.SortSafelist = []string{"id", "title", "year", "runtime", "average_rating", "review_count", "-id", "-title", "-year", "-runtime", "-average_rating", "-review_count"}
 
 	if data.ValidateFilters(v, input.Filters); !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
 
-	movies, metadata, err := app.models.Movies.GetAll(input.Title, input.Genres, input.Filters)
+	orgID, ok := app.activeOrganization(w, r)
+	if !ok{
+		return
+	}
+
+	movies, metadata, err := app.models.Movies.GetAll(input.Title, input.Genres, input.Director, input.Actor, orgID, input.Filters)
 	if err != nil{
 		app.serverErrorResponse(w, r, err)
 		return
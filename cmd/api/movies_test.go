@@ -0,0 +1,122 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"greenlight/internal/data"
+)
+
+func TestMovieRoutesRequireWritePermission(t *testing.T){
+	app := newTestApplication(t)
+	readerToken := newTestToken(t, app, 1, "movies:read")
+
+	body := `{"title": "Moana", "year": 2016, "runtime": "107 mins", "genres": ["animation"]}`
+
+	tests := []struct{
+		name string
+		method string
+		path string
+	}{
+		{"create", http.MethodPost, "/v1/movies"},
+		{"update", http.MethodPatch, "/v1/movies/1"},
+		{"delete", http.MethodDelete, "/v1/movies/1"},
+		{"restore revision", http.MethodPost, "/v1/movies/1/revisions/1/restore"},
+	}
+
+	for _, tt := range tests{
+		t.Run(tt.name, func(t *testing.T){
+			status := executeRequest(t, app, tt.method, tt.path, readerToken, body)
+			assertStatus(t, status, http.StatusForbidden)
+		})
+	}
+}
+
+func TestMovieRoutesRequireReadPermission(t *testing.T){
+	app := newTestApplication(t)
+	token := newTestToken(t, app, 1)
+
+	tests := []struct{
+		name string
+		path string
+	}{
+		{"list", "/v1/movies"},
+		{"show", "/v1/movies/1"},
+	}
+
+	for _, tt := range tests{
+		t.Run(tt.name, func(t *testing.T){
+			status := executeRequest(t, app, http.MethodGet, tt.path, token, "")
+			assertStatus(t, status, http.StatusForbidden)
+		})
+	}
+}
+
+func TestMovieRoutesRequireAuthentication(t *testing.T){
+	app := newTestApplication(t)
+
+	status := executeRequest(t, app, http.MethodPost, "/v1/movies", "", `{}`)
+	assertStatus(t, status, http.StatusUnauthorized)
+}
+
+// the mock models have no database so this would panic if
+// requirePermission did not use the permissions already in the context
+func TestRequirePermissionUsesContextPermissions(t *testing.T){
+	app := newTestApplication(t)
+
+	called := 0
+	next := func(w http.ResponseWriter, r *http.Request){
+		called++
+
+		permissions, ok := app.contextGetPermissions(r)
+		if !ok || !permissions.Include("movies:read"){
+			t.Errorf("permissions missing from context in handler")
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+
+	handler := app.requirePermission("movies:read", app.requirePermission("movies:read", next))
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/movies", nil)
+	req = app.contextSetUser(req, &data.User{ID: 1, Activated: true})
+	req = app.contextSetPermissions(req, data.Permissions{"movies:read"})
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	assertStatus(t, rr.Code, http.StatusOK)
+	if called != 1{
+		t.Errorf("handler called %d times; want 1", called)
+	}
+}
+
+func TestTrashPurgeRequiresPurgePermission(t *testing.T){
+	app := newTestApplication(t)
+	writerToken := newTestToken(t, app, 1, "movies:read", "movies:write", "movies:moderate")
+
+	status := executeRequest(t, app, http.MethodDelete, "/v1/trash/movies/1", writerToken, "")
+	assertStatus(t, status, http.StatusForbidden)
+}
+
+// people are shared by every organization so writers can add them but only
+// moderators can change or delete them
+func TestPeopleRoutesRequireModeratePermission(t *testing.T){
+	app := newTestApplication(t)
+	writerToken := newTestToken(t, app, 1, "movies:read", "movies:write")
+
+	tests := []struct{
+		name string
+		method string
+	}{
+		{"update", http.MethodPatch},
+		{"delete", http.MethodDelete},
+	}
+
+	for _, tt := range tests{
+		t.Run(tt.name, func(t *testing.T){
+			status := executeRequest(t, app, tt.method, "/v1/people/1", writerToken, `{"name": "Ron Clements"}`)
+			assertStatus(t, status, http.StatusForbidden)
+		})
+	}
+}
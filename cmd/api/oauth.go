@@ -0,0 +1,391 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"greenlight/internal/data"
+	"greenlight/internal/validator"
+)
+
+// how long a authorization code can wait before being exchanged
+const oauthCodeTTL = 10 * time.Minute
+
+func (app *application) createOAuthClientHandler(w http.ResponseWriter, r *http.Request){
+	user := app.contextGetUser(r)
+
+	var input struct{
+		Name string `json:"name"`
+		RedirectURIs []string `json:"redirect_uris"`
+		Scopes []string `json:"scopes"`
+		Confidential bool `json:"confidential"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil{
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	client := &data.OAuthClient{
+		UserID: user.ID,
+		Name: input.Name,
+		RedirectURIs: input.RedirectURIs,
+		Scopes: input.Scopes,
+		Confidential: input.Confidential,
+	}
+
+	v := validator.New()
+
+	if data.ValidateOAuthClient(v, client); !v.Valid(){
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.OAuthClients.Insert(client)
+	if err != nil{
+		switch{
+		case errors.Is(err, data.ErrUnknownPermission):
+			v.AddError("scopes", "must only contain existing permission codes")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// the client secret is only shown in this response
+	err = app.writeJSON(w, http.StatusCreated, envelope{"client": client}, nil)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) listOAuthClientsHandler(w http.ResponseWriter, r *http.Request){
+	user := app.contextGetUser(r)
+
+	clients, err := app.models.OAuthClients.GetAllForUser(user.ID)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"clients": clients}, nil)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteOAuthClientHandler(w http.ResponseWriter, r *http.Request){
+	user := app.contextGetUser(r)
+
+	id, err := app.readIDParam(r)
+	if err != nil{
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.models.OAuthClients.Delete(id, user.ID)
+	if err != nil{
+		switch{
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "client successfully deleted"}, nil)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+type authorizeRequest struct{
+	client *data.OAuthClient
+	redirectURI string
+	scopes []string
+	state string
+	codeChallenge string
+}
+
+// read and check the authorization request from the query string, the
+// redirect uri is checked first so we never redirect to an unknown place
+func (app *application) readAuthorizeRequest(r *http.Request, v *validator.Validator) (*authorizeRequest, error){
+	qs := r.URL.Query()
+
+	req := &authorizeRequest{
+		redirectURI: qs.Get("redirect_uri"),
+		scopes: strings.Fields(qs.Get("scope")),
+		state: qs.Get("state"),
+		codeChallenge: qs.Get("code_challenge"),
+	}
+
+	v.Check(qs.Get("response_type") == "code", "response_type", "must be code")
+	v.Check(qs.Get("client_id") != "", "client_id", "must be provided")
+	v.Check(req.codeChallenge != "", "code_challenge", "must be provided")
+	v.Check(qs.Get("code_challenge_method") == "S256", "code_challenge_method", "must be S256")
+	v.Check(len(req.scopes) >= 1, "scope", "must contain at least 1 scope")
+
+	if !v.Valid(){
+		return nil, nil
+	}
+
+	client, err := app.models.OAuthClients.GetByClientID(qs.Get("client_id"))
+	if err != nil{
+		switch{
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("client_id", "unknown client")
+			return nil, nil
+		default:
+			return nil, err
+		}
+	}
+	req.client = client
+
+	if !client.AllowsRedirectURI(req.redirectURI){
+		v.AddError("redirect_uri", "is not registered for this client")
+		return nil, nil
+	}
+
+	for _, scope := range req.scopes{
+		v.Check(validator.PermittedValue(scope, client.Scopes...), "scope", "must only contain scopes allowed for this client")
+	}
+
+	if !v.Valid(){
+		return nil, nil
+	}
+
+	// the user can't give the app more than they have
+	user := app.contextGetUser(r)
+
+	permissions, err := app.models.Permissions.GetEffectiveForUser(user.ID)
+	if err != nil{
+		return nil, err
+	}
+
+	v.Check(len(permissions.Restrict(req.scopes)) == len(req.scopes), "scope", "must only contain permissions you have")
+
+	return req, nil
+}
+
+// first step of the consent, show the user what the app is asking for
+func (app *application) showOAuthAuthorizeHandler(w http.ResponseWriter, r *http.Request){
+	v := validator.New()
+
+	req, err := app.readAuthorizeRequest(r, v)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if !v.Valid(){
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	env := envelope{
+		"client": envelope{"client_id": req.client.ClientID, "name": req.client.Name},
+		"scopes": req.scopes,
+		"redirect_uri": req.redirectURI,
+		"state": req.state,
+	}
+
+	err = app.writeJSON(w, http.StatusOK, env, nil)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// second step of the consent, the user approve or deny and we give back
+// the uri the user agent must be sent to
+func (app *application) createOAuthAuthorizeHandler(w http.ResponseWriter, r *http.Request){
+	user := app.contextGetUser(r)
+
+	var input struct{
+		Approve *bool `json:"approve"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil{
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	if v.Check(input.Approve != nil, "approve", "must be provided"); !v.Valid(){
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	req, err := app.readAuthorizeRequest(r, v)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if !v.Valid(){
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	values := url.Values{}
+	if req.state != ""{
+		values.Set("state", req.state)
+	}
+
+	if *input.Approve{
+		code, err := app.models.OAuthCodes.New(&data.OAuthCode{
+			ClientID: req.client.ClientID,
+			UserID: user.ID,
+			RedirectURI: req.redirectURI,
+			Scopes: req.scopes,
+			CodeChallenge: req.codeChallenge,
+		}, oauthCodeTTL)
+		if err != nil{
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		values.Set("code", code)
+	} else{
+		values.Set("error", "access_denied")
+	}
+
+	separator := "?"
+	if strings.Contains(req.redirectURI, "?"){
+		separator = "&"
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"redirect_uri": req.redirectURI + separator + values.Encode()}, nil)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// the token endpoint read a form body as asked by RFC 6749
+func (app *application) createOAuthTokenHandler(w http.ResponseWriter, r *http.Request){
+	r.Body = http.MaxBytesReader(w, r.Body, 1_048_576)
+
+	err := r.ParseForm()
+	if err != nil{
+		app.oauthErrorResponse(w, r, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+
+	// client can authenticate with basic auth or in the body
+	clientID, clientSecret, ok := r.BasicAuth()
+	if !ok{
+		clientID = r.PostForm.Get("client_id")
+		clientSecret = r.PostForm.Get("client_secret")
+	}
+
+	client, err := app.models.OAuthClients.GetByClientID(clientID)
+	if err != nil{
+		switch{
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.oauthErrorResponse(w, r, http.StatusUnauthorized, "invalid_client", "unknown client")
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if client.Confidential && !client.MatchesSecret(clientSecret){
+		app.oauthErrorResponse(w, r, http.StatusUnauthorized, "invalid_client", "invalid client credentials")
+		return
+	}
+
+	var userID int64
+	var scopes []string
+
+	switch r.PostForm.Get("grant_type"){
+	case "authorization_code":
+		code, err := app.models.OAuthCodes.Consume(r.PostForm.Get("code"))
+		if err != nil{
+			switch{
+			case errors.Is(err, data.ErrRecordNotFound):
+				app.oauthErrorResponse(w, r, http.StatusBadRequest, "invalid_grant", "invalid or expired authorization code")
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+
+		if code.ClientID != client.ClientID || code.RedirectURI != r.PostForm.Get("redirect_uri"){
+			app.oauthErrorResponse(w, r, http.StatusBadRequest, "invalid_grant", "authorization code was issued for another client or redirect_uri")
+			return
+		}
+
+		if !verifyCodeChallenge(r.PostForm.Get("code_verifier"), code.CodeChallenge){
+			app.oauthErrorResponse(w, r, http.StatusBadRequest, "invalid_grant", "invalid code_verifier")
+			return
+		}
+
+		userID = code.UserID
+		scopes = code.Scopes
+
+	case "client_credentials":
+		// the client act as the user who registered it
+		if !client.Confidential{
+			app.oauthErrorResponse(w, r, http.StatusUnauthorized, "invalid_client", "client_credentials need a confidential client")
+			return
+		}
+
+		scopes = strings.Fields(r.PostForm.Get("scope"))
+		if len(scopes) == 0{
+			scopes = client.Scopes
+		}
+
+		for _, scope := range scopes{
+			if !validator.PermittedValue(scope, client.Scopes...){
+				app.oauthErrorResponse(w, r, http.StatusBadRequest, "invalid_scope", fmt.Sprintf("scope %q is not allowed for this client", scope))
+				return
+			}
+		}
+
+		userID = client.UserID
+
+	default:
+		app.oauthErrorResponse(w, r, http.StatusBadRequest, "unsupported_grant_type", "grant_type must be authorization_code or client_credentials")
+		return
+	}
+
+	token, err := app.models.Tokens.NewForClient(userID, app.config.tokens.authenticationTTL, client.ClientID, scopes)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	env := envelope{
+		"access_token": token.Plaintext,
+		"token_type": "Bearer",
+		"expires_in": int(app.config.tokens.authenticationTTL.Seconds()),
+		"scope": strings.Join(scopes, " "),
+	}
+
+	err = app.writeJSON(w, http.StatusOK, env, http.Header{"Cache-Control": []string{"no-store"}})
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// PKCE S256 check (RFC 7636)
+func verifyCodeChallenge(verifier, challenge string) bool{
+	if len(verifier) < 43 || len(verifier) > 128{
+		return false
+	}
+
+	hash := sha256.Sum256([]byte(verifier))
+	expected := base64.RawURLEncoding.EncodeToString(hash[:])
+
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(challenge)) == 1
+}
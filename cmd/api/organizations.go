@@ -0,0 +1,286 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"greenlight/internal/data"
+	"greenlight/internal/validator"
+)
+
+// return the organization the request act in, the header win over the
+// organization of the session. nil mean the shared catalog. when ok is
+// false the error response was already sent
+func (app *application) activeOrganization(w http.ResponseWriter, r *http.Request) (*int64, bool){
+	w.Header().Add("Vary", "X-Organization-ID")
+
+	var orgID int64
+
+	if header := r.Header.Get("X-Organization-ID"); header != ""{
+		id, err := strconv.ParseInt(header, 10, 64)
+		if err != nil || id < 1{
+			app.badRequestResponse(w, r, errors.New("invalid X-Organization-ID header"))
+			return nil, false
+		}
+		orgID = id
+	} else if id, ok := app.contextGetOrganization(r); ok{
+		orgID = id
+	} else{
+		return nil, true
+	}
+
+	// checked on every request so a removed member lose access right away
+	user := app.contextGetUser(r)
+
+	_, err := app.models.Organizations.GetMember(orgID, user.ID)
+	if err != nil{
+		switch{
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notPermittedResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+
+	return &orgID, true
+}
+
+func (app *application) createOrganizationHandler(w http.ResponseWriter, r *http.Request){
+	user := app.contextGetUser(r)
+
+	var input struct{
+		Name string `json:"name"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil{
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	org := &data.Organization{
+		Name: input.Name,
+	}
+
+	v := validator.New()
+
+	if data.ValidateOrganization(v, org); !v.Valid(){
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Organizations.Insert(org, user.ID)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"organization": org}, nil)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) listOrganizationsHandler(w http.ResponseWriter, r *http.Request){
+	user := app.contextGetUser(r)
+
+	orgs, err := app.models.Organizations.GetAllForUser(user.ID)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"organizations": orgs}, nil)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// owner and admin of the organization invite a user with a role, only a
+// owner can invite another owner
+func (app *application) addOrganizationMemberHandler(w http.ResponseWriter, r *http.Request){
+	user := app.contextGetUser(r)
+
+	orgID, err := app.readIDParam(r)
+	if err != nil{
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	caller, err := app.models.Organizations.GetMember(orgID, user.ID)
+	if err != nil{
+		switch{
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if !caller.CanManage(){
+		app.notPermittedResponse(w, r)
+		return
+	}
+
+	var input struct{
+		Email string `json:"email"`
+		Role string `json:"role"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil{
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.Role == ""{
+		input.Role = data.OrgRoleMember
+	}
+
+	v := validator.New()
+
+	data.ValidateEmail(v, input.Email)
+	data.ValidateOrgRole(v, input.Role)
+
+	if !v.Valid(){
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if input.Role == data.OrgRoleOwner && caller.Role != data.OrgRoleOwner{
+		app.notPermittedResponse(w, r)
+		return
+	}
+
+	// the same answer is given whether the email belong to a user or not, and
+	// the user only join once they accept the invitation mailed to them
+	invited, err := app.models.Users.GetByEmail(input.Email)
+	switch{
+	case err == nil:
+		token, err := app.models.Organizations.Invite(orgID, invited.ID, input.Role, user.ID, invitationTTL)
+		if err != nil && !errors.Is(err, data.ErrDuplicateMember){
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		if err == nil{
+			app.backgroud(func(){
+				data := map[string]any{
+					"orgID": orgID,
+					"role": input.Role,
+					"invitationToken": token,
+				}
+
+				err := app.mailer.Send(invited.Email, "organization_invitation.tmpl", data)
+				if err != nil{
+					app.logger.PrintError(err, nil)
+				}
+			})
+		}
+	case !errors.Is(err, data.ErrRecordNotFound):
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	env := envelope{"message": "if an account exists for this email address it will receive an invitation to join the organization"}
+
+	err = app.writeJSON(w, http.StatusAccepted, env, nil)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// the invited user join the organization with the role they were invited with
+func (app *application) acceptOrganizationInvitationHandler(w http.ResponseWriter, r *http.Request){
+	user := app.contextGetUser(r)
+
+	orgID, err := app.readIDParam(r)
+	if err != nil{
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	var input struct{
+		Token string `json:"token"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil{
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	if data.ValidateTokenPlaintext(v, input.Token); !v.Valid(){
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	member, err := app.models.Organizations.AcceptInvitation(orgID, user.ID, input.Token)
+	if err != nil{
+		switch{
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("token", "invalid or expired invitation token")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrDuplicateMember):
+			v.AddError("token", "you are already a member of the organization")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"member": member}, nil)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// start a new session in the organization, the tokens carry it so the
+// X-Organization-ID header is not needed anymore
+// the new tokens have every permission of the user so a restricted api key
+// or oauth token can't use this to get out of its scope
+func (app *application) switchOrganizationHandler(w http.ResponseWriter, r *http.Request){
+	if app.contextIsDelegated(r){
+		app.delegatedCredentialResponse(w, r)
+		return
+	}
+
+	user, ok := app.currentUser(w, r)
+	if !ok{
+		return
+	}
+
+	orgID, err := app.readIDParam(r)
+	if err != nil{
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	_, err = app.models.Organizations.GetMember(orgID, user.ID)
+	if err != nil{
+		switch{
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	env, err := app.issueAuthenticationTokens(r, user, "", &orgID)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, env, nil)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+	}
+}
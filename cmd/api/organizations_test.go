@@ -0,0 +1,32 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"greenlight/internal/data"
+)
+
+func TestSwitchOrganizationRefusesDelegatedCredential(t *testing.T){
+	app := newTestApplication(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/orgs/1/switch", nil)
+	req = app.contextSetUser(req, &data.User{ID: 1, Activated: true})
+	req = app.contextSetPermissions(req, data.Permissions{"movies:read"})
+	req = app.contextSetDelegated(req)
+
+	rr := httptest.NewRecorder()
+	app.switchOrganizationHandler(rr, req)
+
+	assertStatus(t, rr.Code, http.StatusForbidden)
+}
+
+// the token is validated before the invitation is looked up
+func TestAcceptOrganizationInvitationRequireToken(t *testing.T){
+	app := newTestApplication(t)
+	token := newTestToken(t, app, 1, "movies:read")
+
+	status := executeRequest(t, app, http.MethodPost, "/v1/orgs/1/members/accept", token, `{"token": ""}`)
+	assertStatus(t, status, http.StatusUnprocessableEntity)
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"greenlight/internal/data"
+	"greenlight/internal/validator"
+)
+
+// load the person of the :id parameter, when ok is false the error
+// response was already sent
+func (app *application) readPerson(w http.ResponseWriter, r *http.Request) (*data.Person, bool){
+	id, err := app.readIDParam(r)
+	if err != nil{
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	person, err := app.models.People.Get(id)
+	if err != nil{
+		switch{
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+
+	return person, true
+}
+
+func (app *application) createPersonHandler(w http.ResponseWriter, r *http.Request){
+	var input struct{
+		Name string `json:"name"`
+		BirthYear *int32 `json:"birth_year"`
+		Bio string `json:"bio"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil{
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	person := &data.Person{
+		Name: input.Name,
+		BirthYear: input.BirthYear,
+		Bio: input.Bio,
+		CreatedBy: &user.ID,
+	}
+
+	v := validator.New()
+
+	if data.ValidatePerson(v, person); !v.Valid(){
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.People.Insert(person)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/people/%d", person.ID))
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"person": person}, headers)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) showPersonHandler(w http.ResponseWriter, r *http.Request){
+	person, ok := app.readPerson(w, r)
+	if !ok{
+		return
+	}
+
+	err := app.writeJSON(w, http.StatusOK, envelope{"person": person}, nil)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) listPeopleHandler(w http.ResponseWriter, r *http.Request){
+	var input struct{
+		Name string
+		data.Filters
+	}
+
+	v := validator.New()
+
+	qs := r.URL.Query()
+
+	input.Name = app.readStirng(qs, "name", "")
+
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+
+	input.Filters.Sort = app.readStirng(qs, "sort", "name")
+	input.Filters.SortSafelist = []string{"id", "name", "birth_year", "-id", "-name", "-birth_year"}
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid(){
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	people, metadata, err := app.models.People.GetAll(input.Name, input.Filters)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"people": people, "metadata": metadata}, nil)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) updatePersonHandler(w http.ResponseWriter, r *http.Request){
+	person, ok := app.readPerson(w, r)
+	if !ok{
+		return
+	}
+
+	var input struct{
+		Name *string `json:"name"`
+		BirthYear *int32 `json:"birth_year"`
+		Bio *string `json:"bio"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil{
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.Name != nil{
+		person.Name = *input.Name
+	}
+	if input.BirthYear != nil{
+		person.BirthYear = input.BirthYear
+	}
+	if input.Bio != nil{
+		person.Bio = *input.Bio
+	}
+
+	v := validator.New()
+
+	if data.ValidatePerson(v, person); !v.Valid(){
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.People.Update(person)
+	if err != nil{
+		switch{
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"person": person}, nil)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deletePersonHandler(w http.ResponseWriter, r *http.Request){
+	person, ok := app.readPerson(w, r)
+	if !ok{
+		return
+	}
+
+	err := app.models.People.Delete(person.ID)
+	if err != nil{
+		switch{
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, data.ErrPersonCredited):
+			v := validator.New()
+			v.AddError("id", "the person is still credited on movies, remove the credits first")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "person successfully deleted"}, nil)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// the movies of the active organization the person is credited on
+func (app *application) showFilmographyHandler(w http.ResponseWriter, r *http.Request){
+	person, ok := app.readPerson(w, r)
+	if !ok{
+		return
+	}
+
+	var input struct{
+		data.Filters
+	}
+
+	v := validator.New()
+
+	qs := r.URL.Query()
+
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+
+	input.Filters.Sort = app.readStirng(qs, "sort", "-year")
+	input.Filters.SortSafelist = []string{"year", "title", "role", "-year", "-title", "-role"}
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid(){
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	orgID, ok := app.activeOrganization(w, r)
+	if !ok{
+		return
+	}
+
+	filmography, metadata, err := app.models.People.GetFilmography(person.ID, orgID, input.Filters)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"person": person, "filmography": filmography, "metadata": metadata}, nil)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+	}
+}
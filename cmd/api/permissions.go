@@ -0,0 +1,149 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"greenlight/internal/data"
+	"greenlight/internal/validator"
+)
+
+func (app *application) showUserPermissionsHandler(w http.ResponseWriter, r *http.Request){
+	id, err := app.readIDParam(r)
+	if err != nil{
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	_, err = app.models.Users.Get(id)
+	if err != nil{
+		switch{
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.writeUserPermissions(w, r, id)
+}
+
+func (app *application) addUserPermissionsHandler(w http.ResponseWriter, r *http.Request){
+	id, err := app.readIDParam(r)
+	if err != nil{
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	var input struct{
+		Codes []string `json:"codes"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil{
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	if data.ValidatePermissionCodes(v, input.Codes); !v.Valid(){
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	_, err = app.models.Users.Get(id)
+	if err != nil{
+		switch{
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.models.Permissions.AddForUser(id, input.Codes...)
+	if err != nil{
+		switch{
+		case errors.Is(err, data.ErrUnknownPermission):
+			v.AddError("codes", "must only contain existing permission codes")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.writeUserPermissions(w, r, id)
+}
+
+// the codes to remove are given in the query string like ?codes=movies:read,movies:write
+func (app *application) removeUserPermissionsHandler(w http.ResponseWriter, r *http.Request){
+	id, err := app.readIDParam(r)
+	if err != nil{
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	codes := app.readCSV(r.URL.Query(), "codes", []string{})
+
+	v := validator.New()
+
+	if data.ValidatePermissionCodes(v, codes); !v.Valid(){
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	_, err = app.models.Users.Get(id)
+	if err != nil{
+		switch{
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.models.Permissions.RemoveForUser(id, codes...)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.writeUserPermissions(w, r, id)
+}
+
+// send the direct permissions, the roles and the effective permissions of the user
+func (app *application) writeUserPermissions(w http.ResponseWriter, r *http.Request, userID int64){
+	permissions, err := app.models.Permissions.GetAllForUser(userID)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	roles, err := app.models.Roles.GetAllForUser(userID)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	effective, err := app.models.Permissions.GetEffectiveForUser(userID)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	env := envelope{
+		"permissions": permissions,
+		"roles": roles,
+		"effective_permissions": effective,
+	}
+
+	err = app.writeJSON(w, http.StatusOK, env, nil)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"greenlight/internal/data"
+	"greenlight/internal/policy"
+	"greenlight/internal/validator"
+)
+
+// load the movie of the :id parameter in the active organization, when
+// ok is false the error response was already sent
+func (app *application) readMovieParam(w http.ResponseWriter, r *http.Request) (*data.Movie, bool){
+	id, err := app.readIDParam(r)
+	if err != nil{
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	orgID, ok := app.activeOrganization(w, r)
+	if !ok{
+		return nil, false
+	}
+
+	movie, err := app.models.Movies.Get(id, orgID)
+	if err != nil{
+		switch{
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+
+	return movie, true
+}
+
+// same for the :review_id parameter of the movie
+func (app *application) readReview(w http.ResponseWriter, r *http.Request) (*data.Review, bool){
+	movie, ok := app.readMovieParam(w, r)
+	if !ok{
+		return nil, false
+	}
+
+	id, err := app.readNamedIDParam(r, "review_id")
+	if err != nil{
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	review, err := app.models.Reviews.Get(id, movie.ID)
+	if err != nil{
+		switch{
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+
+	return review, true
+}
+
+func (app *application) createReviewHandler(w http.ResponseWriter, r *http.Request){
+	movie, ok := app.readMovieParam(w, r)
+	if !ok{
+		return
+	}
+
+	var input struct{
+		Rating int `json:"rating"`
+		Body string `json:"body"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil{
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	review := &data.Review{
+		MovieID: movie.ID,
+		UserID: user.ID,
+		Rating: input.Rating,
+		Body: input.Body,
+	}
+
+	v := validator.New()
+
+	if data.ValidateReview(v, review); !v.Valid(){
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Reviews.Insert(review)
+	if err != nil{
+		switch{
+		case errors.Is(err, data.ErrDuplicateReview):
+			v.AddError("movie", "you already reviewed this movie")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/movies/%d/reviews/%d", movie.ID, review.ID))
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"review": review}, headers)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) showReviewHandler(w http.ResponseWriter, r *http.Request){
+	review, ok := app.readReview(w, r)
+	if !ok{
+		return
+	}
+
+	err := app.writeJSON(w, http.StatusOK, envelope{"review": review}, nil)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) listReviewsHandler(w http.ResponseWriter, r *http.Request){
+	movie, ok := app.readMovieParam(w, r)
+	if !ok{
+		return
+	}
+
+	var input struct{
+		data.Filters
+	}
+
+	v := validator.New()
+
+	qs := r.URL.Query()
+
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+
+	input.Filters.Sort = app.readStirng(qs, "sort", "-created_at")
+	input.Filters.SortSafelist = []string{"id", "rating", "created_at", "-id", "-rating", "-created_at"}
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid(){
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	reviews, metadata, err := app.models.Reviews.GetAllForMovie(movie.ID, input.Filters)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	env := envelope{
+		"reviews": reviews,
+		"average_rating": movie.AverageRating,
+		"review_count": movie.ReviewCount,
+		"metadata": metadata,
+	}
+
+	err = app.writeJSON(w, http.StatusOK, env, nil)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) updateReviewHandler(w http.ResponseWriter, r *http.Request){
+	review, ok := app.readReview(w, r)
+	if !ok{
+		return
+	}
+
+	err := app.authorize(r, policy.ReviewWrite, review)
+	if err != nil{
+		switch{
+		case errors.Is(err, policy.ErrForbidden):
+			app.notPermittedResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	var input struct{
+		Rating *int `json:"rating"`
+		Body *string `json:"body"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil{
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.Rating != nil{
+		review.Rating = *input.Rating
+	}
+	if input.Body != nil{
+		review.Body = *input.Body
+	}
+
+	v := validator.New()
+
+	if data.ValidateReview(v, review); !v.Valid(){
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Reviews.Update(review)
+	if err != nil{
+		switch{
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"review": review}, nil)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteReviewHandler(w http.ResponseWriter, r *http.Request){
+	review, ok := app.readReview(w, r)
+	if !ok{
+		return
+	}
+
+	err := app.authorize(r, policy.ReviewWrite, review)
+	if err != nil{
+		switch{
+		case errors.Is(err, policy.ErrForbidden):
+			app.notPermittedResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.models.Reviews.Delete(review.ID, review.MovieID)
+	if err != nil{
+		switch{
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "review successfully deleted"}, nil)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+	}
+}
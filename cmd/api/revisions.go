@@ -0,0 +1,182 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	"greenlight/internal/data"
+	"greenlight/internal/validator"
+)
+
+// load the revision of the :version parameter for the movie, when ok is
+// false the error response was already sent
+func (app *application) readRevision(w http.ResponseWriter, r *http.Request, movie *data.Movie) (*data.MovieRevision, bool){
+	version, err := app.readNamedIDParam(r, "version")
+	if err != nil || version > math.MaxInt32{
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	revision, err := app.models.Revisions.Get(movie.ID, int32(version))
+	if err != nil{
+		switch{
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+
+	return revision, true
+}
+
+func (app *application) listRevisionsHandler(w http.ResponseWriter, r *http.Request){
+	movie, ok := app.readMovieParam(w, r)
+	if !ok{
+		return
+	}
+
+	var input struct{
+		data.Filters
+	}
+
+	v := validator.New()
+
+	qs := r.URL.Query()
+
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+
+	input.Filters.Sort = app.readStirng(qs, "sort", "-version")
+	input.Filters.SortSafelist = []string{"version", "-version"}
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid(){
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	revisions, metadata, err := app.models.Revisions.GetAllForMovie(movie.ID, input.Filters)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"revisions": revisions, "metadata": metadata}, nil)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) showRevisionHandler(w http.ResponseWriter, r *http.Request){
+	movie, ok := app.readMovieParam(w, r)
+	if !ok{
+		return
+	}
+
+	revision, ok := app.readRevision(w, r, movie)
+	if !ok{
+		return
+	}
+
+	err := app.writeJSON(w, http.StatusOK, envelope{"revision": revision}, nil)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// the changes made between the version in ?from= (the previous version by
+// default) and the :version parameter
+func (app *application) diffRevisionHandler(w http.ResponseWriter, r *http.Request){
+	movie, ok := app.readMovieParam(w, r)
+	if !ok{
+		return
+	}
+
+	to, ok := app.readRevision(w, r, movie)
+	if !ok{
+		return
+	}
+
+	v := validator.New()
+
+	fromVersion := app.readInt(r.URL.Query(), "from", int(to.Version)-1, v)
+
+	if v.Check(fromVersion >= 1 && fromVersion <= math.MaxInt32, "from", "must be an existing version"); !v.Valid(){
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	from, err := app.models.Revisions.Get(movie.ID, int32(fromVersion))
+	if err != nil{
+		switch{
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("from", "must be an existing version")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	diff := envelope{
+		"from": from.Version,
+		"to": to.Version,
+		"changes": data.DiffRevisions(from, to),
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"diff": diff}, nil)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// put the movie back in the state of an old version, this is a normal update
+// so it create a new version and fail if the movie changed in the meantime
+func (app *application) restoreRevisionHandler(w http.ResponseWriter, r *http.Request){
+	movie, ok := app.readWritableMovie(w, r)
+	if !ok{
+		return
+	}
+
+	revision, ok := app.readRevision(w, r, movie)
+	if !ok{
+		return
+	}
+
+	if r.Header.Get("X-Expected-Version") != ""{
+		if strconv.FormatInt(int64(movie.Version), 10) != r.Header.Get("X-Expected-Version") {
+			app.editConflictResponse(w, r)
+			return
+		}
+	}
+
+	movie.Title = revision.Title
+	movie.Year = revision.Year
+	movie.Runtime = revision.Runtime
+	movie.Genres = revision.Genres
+
+	v := validator.New()
+	if data.ValidateMovie(v, movie); !v.Valid(){
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err := app.models.Movies.Update(movie, app.contextGetUser(r).ID)
+	if err != nil{
+		switch{
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"movie": movie}, nil)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -10,8 +10,15 @@ func (app *application) routes() http.Handler{
 	
 	router := httprouter.New()
 
+	// httprouter panic when a :id segment is next to a static one (like
+	// /v1/users/activated) so routes for a given user live in there own
+	// router which is used when the main router find nothing
+	userRouter := httprouter.New()
+	userRouter.NotFound = http.HandlerFunc(app.notFoundResponse)
+	userRouter.MethodNotAllowed = http.HandlerFunc(app.methodNotAllowedResponse)
+
 	// adding custom error handler for notfound
-	router.NotFound = http.HandlerFunc(app.notFoundResponse)
+	router.NotFound = userRouter
 
 	// adding custom error handler for method not allew
 	router.MethodNotAllowed = http.HandlerFunc(app.methodNotAllowedResponse)
@@ -19,18 +26,127 @@ func (app *application) routes() http.Handler{
 
 	router.HandlerFunc(http.MethodGet, "/v1/healthcheck", app.healthcheckHandler)
 
-	router.HandlerFunc(http.MethodGet, "/v1/movies", app.requireActivatedUser(app.listMovieHandler))
-	router.HandlerFunc(http.MethodPost, "/v1/movies", app.requireActivatedUser(app.createMovieHandler))
-	router.HandlerFunc(http.MethodGet, "/v1/movies/:id", app.requireActivatedUser(app.showMovieHandler))
-	router.HandlerFunc(http.MethodPatch, "/v1/movies/:id", app.requireActivatedUser(app.updateMovieHandler))
-	router.HandlerFunc(http.MethodDelete, "/v1/movies/:id", app.requireActivatedUser(app.deleteMovieHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/movies", app.requirePermission("movies:read", app.listMovieHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/movies", app.requirePermission("movies:write", app.createMovieHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/movies/:id", app.requirePermission("movies:read", app.showMovieHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/movies/:id", app.requirePermission("movies:write", app.updateMovieHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/movies/:id", app.requirePermission("movies:write", app.deleteMovieHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/movies/:id/restore", app.requirePermission("movies:write", app.restoreMovieHandler))
+
+	// route for the trash, purging is only for admins
+	router.HandlerFunc(http.MethodGet, "/v1/trash/movies", app.requirePermission("movies:write", app.listTrashedMoviesHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/trash/movies/:id", app.requirePermission("movies:purge", app.purgeMovieHandler))
+
+	// route for reviews, anyone who can read a movie can review it
+	router.HandlerFunc(http.MethodGet, "/v1/movies/:id/reviews", app.requirePermission("movies:read", app.listReviewsHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/movies/:id/reviews", app.requirePermission("movies:read", app.createReviewHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/movies/:id/reviews/:review_id", app.requirePermission("movies:read", app.showReviewHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/movies/:id/reviews/:review_id", app.requirePermission("movies:read", app.updateReviewHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/movies/:id/reviews/:review_id", app.requirePermission("movies:read", app.deleteReviewHandler))
+
+	// route for the cast and crew of a movie
+	router.HandlerFunc(http.MethodGet, "/v1/movies/:id/credits", app.requirePermission("movies:read", app.listCreditsHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/movies/:id/credits", app.requirePermission("movies:write", app.createCreditHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/movies/:id/credits/:credit_id", app.requirePermission("movies:write", app.updateCreditHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/movies/:id/credits/:credit_id", app.requirePermission("movies:write", app.deleteCreditHandler))
 
-	// route for users
+	// route for the history of a movie
+	router.HandlerFunc(http.MethodGet, "/v1/movies/:id/revisions", app.requirePermission("movies:read", app.listRevisionsHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/movies/:id/revisions/:version", app.requirePermission("movies:read", app.showRevisionHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/movies/:id/revisions/:version/diff", app.requirePermission("movies:read", app.diffRevisionHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/movies/:id/revisions/:version/restore", app.requirePermission("movies:write", app.restoreRevisionHandler))
+
+	// route for people, they are shared by every organization so only the
+	// moderators can change or delete them
+	router.HandlerFunc(http.MethodGet, "/v1/people", app.requirePermission("movies:read", app.listPeopleHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/people", app.requirePermission("movies:write", app.createPersonHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/people/:id", app.requirePermission("movies:read", app.showPersonHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/people/:id", app.requirePermission("movies:moderate", app.updatePersonHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/people/:id", app.requirePermission("movies:moderate", app.deletePersonHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/people/:id/filmography", app.requirePermission("movies:read", app.showFilmographyHandler))
+
+	// route for users, the ones changing the account or its credentials
+	// can't be used with a restricted api key or oauth token
 	router.HandlerFunc(http.MethodPost, "/v1/users", app.registerUserHandler)
 	router.HandlerFunc(http.MethodPut, "/v1/users/activated", app.activateUserHandler)
+	router.HandlerFunc(http.MethodPut, "/v1/users/password", app.updateUserPasswordHandler)
+	router.HandlerFunc(http.MethodPut, "/v1/users/email", app.confirmUserEmailHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/users/export", app.showUserExportHandler)
+	router.HandlerFunc(http.MethodGet, "/v1/users/me", app.requireAuthenticatedUser(app.showCurrentUserHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/users/me", app.requireAuthenticatedUser(app.requireUserCredential(app.updateCurrentUserHandler)))
+	router.HandlerFunc(http.MethodDelete, "/v1/users/me", app.requireAuthenticatedUser(app.requireUserCredential(app.deleteCurrentUserHandler)))
+	router.HandlerFunc(http.MethodPatch, "/v1/users/me/email", app.requireActivatedUser(app.requireUserCredential(app.updateCurrentUserEmailHandler)))
+	router.HandlerFunc(http.MethodPost, "/v1/users/me/export", app.requireAuthenticatedUser(app.requireUserCredential(app.createUserExportHandler)))
+	router.HandlerFunc(http.MethodPost, "/v1/users/me/2fa", app.requireActivatedUser(app.requireUserCredential(app.enableTwoFactorHandler)))
+	router.HandlerFunc(http.MethodPost, "/v1/users/me/2fa/confirm", app.requireActivatedUser(app.requireUserCredential(app.confirmTwoFactorHandler)))
+	router.HandlerFunc(http.MethodGet, "/v1/users/me/api-keys", app.requireActivatedUser(app.listAPIKeysHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/users/me/api-keys", app.requireActivatedUser(app.requireUserCredential(app.createAPIKeyHandler)))
+	router.HandlerFunc(http.MethodDelete, "/v1/users/me/api-keys/:id", app.requireActivatedUser(app.requireUserCredential(app.deleteAPIKeyHandler)))
+	router.HandlerFunc(http.MethodGet, "/v1/users/me/watchlist", app.requireActivatedUser(app.listWatchlistHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/users/me/watchlist", app.requirePermission("movies:read", app.addWatchlistItemHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/users/me/watchlist/:id", app.requireActivatedUser(app.updateWatchlistItemHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/users/me/watchlist/:id", app.requireActivatedUser(app.deleteWatchlistItemHandler))
+
+	// route for permissions administration
+	userRouter.HandlerFunc(http.MethodGet, "/v1/users/:id/permissions", app.requirePermission("permissions:admin", app.showUserPermissionsHandler))
+	userRouter.HandlerFunc(http.MethodPut, "/v1/users/:id/permissions", app.requirePermission("permissions:admin", app.addUserPermissionsHandler))
+	userRouter.HandlerFunc(http.MethodDelete, "/v1/users/:id/permissions", app.requirePermission("permissions:admin", app.removeUserPermissionsHandler))
+
+	userRouter.HandlerFunc(http.MethodPut, "/v1/users/:id/roles", app.requirePermission("roles:admin", app.addUserRolesHandler))
+	userRouter.HandlerFunc(http.MethodDelete, "/v1/users/:id/roles", app.requirePermission("roles:admin", app.removeUserRolesHandler))
+
+	// route for roles
+	router.HandlerFunc(http.MethodGet, "/v1/roles", app.requirePermission("roles:admin", app.listRolesHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/roles", app.requirePermission("roles:admin", app.createRoleHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/roles/:id", app.requirePermission("roles:admin", app.showRoleHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/roles/:id", app.requirePermission("roles:admin", app.updateRoleHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/roles/:id", app.requirePermission("roles:admin", app.deleteRoleHandler))
+
+	// route for oauth2
+	router.HandlerFunc(http.MethodGet, "/v1/oauth/clients", app.requireActivatedUser(app.requireUserCredential(app.listOAuthClientsHandler)))
+	router.HandlerFunc(http.MethodPost, "/v1/oauth/clients", app.requireActivatedUser(app.requireUserCredential(app.createOAuthClientHandler)))
+	router.HandlerFunc(http.MethodDelete, "/v1/oauth/clients/:id", app.requireActivatedUser(app.requireUserCredential(app.deleteOAuthClientHandler)))
+	router.HandlerFunc(http.MethodGet, "/oauth/authorize", app.requireActivatedUser(app.requireUserCredential(app.showOAuthAuthorizeHandler)))
+	router.HandlerFunc(http.MethodPost, "/oauth/authorize", app.requireActivatedUser(app.requireUserCredential(app.createOAuthAuthorizeHandler)))
+	router.HandlerFunc(http.MethodPost, "/oauth/token", app.createOAuthTokenHandler)
+
+	// route for custom lists, public lists can be read by any user
+	router.HandlerFunc(http.MethodGet, "/v1/lists", app.requireActivatedUser(app.listListsHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/lists", app.requireActivatedUser(app.createListHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/lists/:id", app.requireActivatedUser(app.showListHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/lists/:id", app.requireActivatedUser(app.updateListHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/lists/:id", app.requireActivatedUser(app.deleteListHandler))
+	router.HandlerFunc(http.MethodPut, "/v1/lists/:id/items", app.requirePermission("movies:read", app.setListItemHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/lists/:id/items/:movie_id", app.requireActivatedUser(app.deleteListItemHandler))
+
+	// route for organizations
+	router.HandlerFunc(http.MethodGet, "/v1/orgs", app.requireActivatedUser(app.listOrganizationsHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/orgs", app.requireActivatedUser(app.requireUserCredential(app.createOrganizationHandler)))
+	router.HandlerFunc(http.MethodPost, "/v1/orgs/:id/members", app.requireActivatedUser(app.requireUserCredential(app.addOrganizationMemberHandler)))
+	router.HandlerFunc(http.MethodPost, "/v1/orgs/:id/members/accept", app.requireActivatedUser(app.requireUserCredential(app.acceptOrganizationInvitationHandler)))
+	router.HandlerFunc(http.MethodPost, "/v1/orgs/:id/switch", app.requireActivatedUser(app.requireUserCredential(app.switchOrganizationHandler)))
+
+	// route for support staff
+	router.HandlerFunc(http.MethodPost, "/v1/invitations", app.requirePermission("users:admin", app.createInvitationHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/admin/users", app.requirePermission("users:admin", app.listAdminUsersHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/admin/users/:id", app.requirePermission("users:admin", app.showAdminUserHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/admin/users/:id", app.requirePermission("users:admin", app.deleteAdminUserHandler))
+	router.HandlerFunc(http.MethodPut, "/v1/admin/users/:id/activated", app.requirePermission("users:admin", app.updateAdminUserActivationHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/admin/users/:id/password-reset", app.requirePermission("users:admin", app.forceAdminUserPasswordResetHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/admin/users/:id/tokens", app.requirePermission("users:admin", app.deleteAdminUserTokensHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/admin/users/:id/data", app.requirePermission("users:admin", app.eraseAdminUserHandler))
 
 	//router for auth
 	router.HandlerFunc(http.MethodPost, "/v1/tokens/authentication", app.createAuthenticationTokenHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/tokens/authentication/mfa", app.createMFAAuthenticationTokenHandler)
+	router.HandlerFunc(http.MethodGet, "/v1/tokens/authentication", app.requireAuthenticatedUser(app.listAuthenticationTokensHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/tokens/authentication", app.requireAuthenticatedUser(app.deleteAuthenticationTokenHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/tokens/authentication/all", app.requireAuthenticatedUser(app.requireUserCredential(app.deleteAllAuthenticationTokensHandler)))
+	router.HandlerFunc(http.MethodPost, "/v1/tokens/refresh", app.refreshAuthenticationTokenHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/tokens/password-reset", app.createPasswordResetTokenHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/tokens/activation", app.createActivationTokenHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/tokens/magic-link", app.createMagicLinkTokenHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/tokens/magic-link/exchange", app.exchangeMagicLinkTokenHandler)
 
 	return app.recoverPanic(app.rateLimit(app.authenticate(router)))
 }
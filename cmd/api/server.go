@@ -45,6 +45,16 @@ func (app *application) serve() error{
 			shutdownError <- err
 		}
 		
+		// no request can use the limiters anymore
+		app.activationLimiter.stop()
+		app.magicLinkLimiter.stop()
+		app.passwordResetLimiter.stop()
+
+		// no new purge or deny list sync can start once we wait for the
+		// background tasks
+		close(app.sweeperDone)
+		close(app.denylistDone)
+
 		// logging a message to say that we are waititng for any background task to finished
 		app.logger.PrintInfo("completing background tasks", map[string]string{
 			"addr": srv.Addr,
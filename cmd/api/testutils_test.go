@@ -0,0 +1,94 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"greenlight/internal/data"
+	"greenlight/internal/jsonlog"
+	"greenlight/internal/jwt"
+)
+
+// return a application in jwt mode without a database, the request
+// used in test must never reach a model
+func newTestApplication(t *testing.T) *application{
+	t.Helper()
+
+	var cfg config
+	cfg.env = "testing"
+	cfg.auth.mode = authModeJWT
+	cfg.tokens.authenticationTTL = time.Hour
+
+	keys := jwt.NewKeySet()
+
+	err := keys.AddHS256("test", []byte(strings.Repeat("k", 32)))
+	if err != nil{
+		t.Fatal(err)
+	}
+
+	err = keys.SetSigningKey("test")
+	if err != nil{
+		t.Fatal(err)
+	}
+
+	return &application{
+		config: cfg,
+		logger: jsonlog.New(io.Discard, jsonlog.LevelOff),
+		models: data.NewMockModels(),
+		jwtKeys: keys,
+		denylist: newJWTDenylist(),
+		exports: newExportTracker(),
+		sweeperDone: make(chan struct{}),
+		denylistDone: make(chan struct{}),
+	}
+}
+
+// return a signed authentication token for a activated user with the permissions
+func newTestToken(t *testing.T, app *application, userID int64, permissions ...string) string{
+	t.Helper()
+
+	now := time.Now()
+
+	token, err := app.jwtKeys.Sign(jwt.Claims{
+		ID: "test",
+		Subject: userID,
+		Activated: true,
+		Permissions: permissions,
+		IssuedAt: now.Unix(),
+		IssuedAtMicro: now.UnixMicro(),
+		Expiry: now.Add(time.Hour).Unix(),
+	})
+	if err != nil{
+		t.Fatal(err)
+	}
+
+	return token
+}
+
+// send the request through the full middleware chain and return the status
+func executeRequest(t *testing.T, app *application, method, path, token, body string) int{
+	t.Helper()
+
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if token != ""{
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	rr := httptest.NewRecorder()
+	app.routes().ServeHTTP(rr, req)
+
+	return rr.Result().StatusCode
+}
+
+// assert the status is the expected one
+func assertStatus(t *testing.T, got, want int){
+	t.Helper()
+
+	if got != want{
+		t.Errorf("got status %d (%s); want %d (%s)", got, http.StatusText(got), want, http.StatusText(want))
+	}
+}
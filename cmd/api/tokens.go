@@ -1,11 +1,18 @@
 package main
 
 import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
 	"errors"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"greenlight/internal/data"
+	"greenlight/internal/jwt"
 	"greenlight/internal/validator"
 )
 
@@ -31,10 +38,34 @@ func (app *application) createAuthenticationTokenHandler(w http.ResponseWriter,
 		return
 	}
 
+	email := strings.ToLower(input.Email)
+	ip := app.clientIP(r)
+
+	status, retryAfter, err := app.loginThrottle(email, ip)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	switch status{
+	case http.StatusLocked:
+		app.accountLockedResponse(w, r, retryAfter)
+		return
+	case http.StatusTooManyRequests:
+		app.loginBackoffResponse(w, r, retryAfter)
+		return
+	}
+
 	user, err := app.models.Users.GetByEmail(input.Email)
 	if err != nil{
 		switch{
 		case errors.Is(err, data.ErrRecordNotFound):
+			// unknown email count as a failure too so it look the same as a wrong password
+			err = app.recordLoginFailure(email, ip, nil)
+			if err != nil{
+				app.serverErrorResponse(w, r, err)
+				return
+			}
 			app.invalidCredentialsResponse(w, r)
 		default:
 			app.serverErrorResponse(w, r, err)
@@ -48,19 +79,573 @@ func (app *application) createAuthenticationTokenHandler(w http.ResponseWriter,
 	}
 
 	if !match{
+		err = app.recordLoginFailure(email, ip, user)
+		if err != nil{
+			app.serverErrorResponse(w, r, err)
+			return
+		}
 		app.invalidCredentialsResponse(w, r)
 		return
 	}
 
-	token, err := app.models.Tokens.New(user.ID, 24 * time.Hour, data.ScopeAuthentication)
+	err = app.models.LoginFailures.Reset(data.LoginFailureAccount, email)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.loginResponse(w, r, user)
+}
+
+// send the tokens once the user proved who they are, user with 2fa get
+// a short lived token which must be exchanged with a code
+func (app *application) loginResponse(w http.ResponseWriter, r *http.Request, user *data.User){
+	enabled, err := app.models.TwoFactor.Enabled(user.ID)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if enabled{
+		mfaToken, err := app.models.Tokens.New(user.ID, 5*time.Minute, data.ScopeMFAPending)
+		if err != nil{
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		env := envelope{
+			"message": "two-factor code required, send it with the mfa token to POST /v1/tokens/authentication/mfa",
+			"mfa_token": mfaToken,
+		}
+
+		err = app.writeJSON(w, http.StatusAccepted, env, nil)
+		if err != nil{
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	env, err := app.issueAuthenticationTokens(r, user, "", nil)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, env, nil)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// create the authentication and refresh token for a user, in jwt mode
+// the authentication token is signed and not saved in the database.
+// orgID is the organization the session is switched to, nil for none
+func (app *application) issueAuthenticationTokens(r *http.Request, user *data.User, family string, orgID *int64) (envelope, error){
+	if app.config.auth.mode != authModeJWT{
+		authToken, refreshToken, err := app.models.Tokens.NewPair(user.ID, app.config.tokens.authenticationTTL, app.config.tokens.refreshTTL, family, r.UserAgent(), app.clientIP(r), orgID)
+		if err != nil{
+			return nil, err
+		}
+		return envelope{"authentication_token": authToken, "refresh_token": refreshToken}, nil
+	}
+
+	refreshToken, err := app.models.Tokens.NewRefresh(user.ID, app.config.tokens.refreshTTL, family, r.UserAgent(), app.clientIP(r), orgID)
+	if err != nil{
+		return nil, err
+	}
+
+	permissions, err := app.models.Permissions.GetEffectiveForUser(user.ID)
+	if err != nil{
+		return nil, err
+	}
+
+	idBytes := make([]byte, 16)
+	_, err = rand.Read(idBytes)
+	if err != nil{
+		return nil, err
+	}
+
+	now := time.Now()
+	expiry := now.Add(app.config.tokens.authenticationTTL)
+
+	signed, err := app.jwtKeys.Sign(jwt.Claims{
+		ID: base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(idBytes),
+		Subject: user.ID,
+		Activated: user.Activated,
+		Permissions: permissions,
+		Family: refreshToken.Family,
+		Org: orgID,
+		IssuedAt: now.Unix(),
+		IssuedAtMicro: now.UnixMicro(),
+		Expiry: expiry.Unix(),
+	})
+	if err != nil{
+		return nil, err
+	}
+
+	authToken := &data.Token{
+		Plaintext: signed,
+		Expiry: expiry,
+	}
+
+	return envelope{"authentication_token": authToken, "refresh_token": refreshToken}, nil
+}
+
+func (app *application) createPasswordResetTokenHandler(w http.ResponseWriter, r *http.Request){
+
+	var input struct{
+		Email string `json:"email"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil{
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	if data.ValidateEmail(v, input.Email); !v.Valid(){
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	// same as the activation email, limit by email so an inbox can't be flooded
+	if app.config.limiter.emailEnabled && !app.passwordResetLimiter.allow(strings.ToLower(input.Email)){
+		app.rateLimitExceededResponse(w, r)
+		return
+	}
+
+	// we send back the same response if the email is known or not
+	// so no one can use this endpoint to find which account exists
+	env := envelope{"message": "if an account exists for this email address you will receive password reset instructions"}
+
+	user, err := app.models.Users.GetByEmail(input.Email)
+	if err != nil{
+		switch{
+		case errors.Is(err, data.ErrRecordNotFound):
+			err = app.writeJSON(w, http.StatusAccepted, env, nil)
+			if err != nil{
+				app.serverErrorResponse(w, r, err)
+			}
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	token, err := app.models.Tokens.New(user.ID, 45*time.Minute, data.ScopePasswordReset)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.backgroud(func(){
+		data := map[string]any{
+			"passwordResetToken": token.Plaintext,
+		}
+
+		err = app.mailer.Send(user.Email, "token_password_reset.tmpl", data)
+		if err != nil{
+			app.logger.PrintError(err, nil)
+		}
+	})
+
+	err = app.writeJSON(w, http.StatusAccepted, env, nil)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// passwordless login, the token sent by mail is exchanged for a
+// authentication token with POST /v1/tokens/magic-link/exchange
+func (app *application) createMagicLinkTokenHandler(w http.ResponseWriter, r *http.Request){
+
+	var input struct{
+		Email string `json:"email"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil{
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	if data.ValidateEmail(v, input.Email); !v.Valid(){
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	// same as the activation email, limit by email so an inbox can't be flooded
+	if app.config.limiter.emailEnabled && !app.magicLinkLimiter.allow(strings.ToLower(input.Email)){
+		app.rateLimitExceededResponse(w, r)
+		return
+	}
+
+	// same response if the email is known or not, like for password reset
+	env := envelope{"message": "if an account exists for this email address you will receive a login link"}
+
+	user, err := app.models.Users.GetByEmail(input.Email)
+	if err != nil{
+		switch{
+		case errors.Is(err, data.ErrRecordNotFound):
+			err = app.writeJSON(w, http.StatusAccepted, env, nil)
+			if err != nil{
+				app.serverErrorResponse(w, r, err)
+			}
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// only the last link sent work
+	err = app.models.Tokens.DeletAllForUser(data.ScopeMagicLink, user.ID)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	token, err := app.models.Tokens.New(user.ID, 15*time.Minute, data.ScopeMagicLink)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.backgroud(func(){
+		data := map[string]any{
+			"magicLinkToken": token.Plaintext,
+		}
+
+		err = app.mailer.Send(user.Email, "token_magic_link.tmpl", data)
+		if err != nil{
+			app.logger.PrintError(err, nil)
+		}
+	})
+
+	err = app.writeJSON(w, http.StatusAccepted, env, nil)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) exchangeMagicLinkTokenHandler(w http.ResponseWriter, r *http.Request){
+
+	var input struct{
+		TokenPlaintext string `json:"token"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil{
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	if data.ValidateTokenPlaintext(v, input.TokenPlaintext); !v.Valid(){
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	// the link is single use, it is deleted as it is read so the same
+	// link used twice at once only log in once
+	userID, err := app.models.Tokens.Consume(data.ScopeMagicLink, input.TokenPlaintext)
+	if err != nil{
+		switch{
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("token", "invalid or expired magic link token")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	user, err := app.models.Users.Get(userID)
+	if err != nil{
+		switch{
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("token", "invalid or expired magic link token")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.loginResponse(w, r, user)
+}
+
+func (app *application) createActivationTokenHandler(w http.ResponseWriter, r *http.Request){
+
+	var input struct{
+		Email string `json:"email"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil{
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	if data.ValidateEmail(v, input.Email); !v.Valid(){
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	// limit by email and not ip so someone can't fill a inbox
+	// by changing there ip address, email column is citext so we lower it
+	if app.config.limiter.emailEnabled && !app.activationLimiter.allow(strings.ToLower(input.Email)){
+		app.rateLimitExceededResponse(w, r)
+		return
+	}
+
+	// same response if there is no account or it is already activated, like
+	// for password reset, the email is only sent when there is something to do
+	env := envelope{"message": "if an account needing activation exists for this email address you will receive activation instructions"}
+
+	user, err := app.models.Users.GetByEmail(input.Email)
+	if err != nil{
+		switch{
+		case errors.Is(err, data.ErrRecordNotFound):
+			err = app.writeJSON(w, http.StatusAccepted, env, nil)
+			if err != nil{
+				app.serverErrorResponse(w, r, err)
+			}
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if user.Activated{
+		err = app.writeJSON(w, http.StatusAccepted, env, nil)
+		if err != nil{
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// remove the old activation token so only the new one work
+	err = app.models.Tokens.DeletAllForUser(data.ScopeActivation, user.ID)
 	if err != nil{
 		app.serverErrorResponse(w, r, err)
 		return
 	}
 
-	err = app.writeJSON(w, http.StatusCreated, envelope{"authentication_token": token}, nil)
+	token, err := app.models.Tokens.New(user.ID, 3*24*time.Hour, data.ScopeActivation)
 	if err != nil{
 		app.serverErrorResponse(w, r, err)
+		return
 	}
 
+	app.backgroud(func(){
+		data := map[string]any{
+			"activationToken": token.Plaintext,
+		}
+
+		err = app.mailer.Send(user.Email, "token_activation.tmpl", data)
+		if err != nil{
+			app.logger.PrintError(err, nil)
+		}
+	})
+
+	err = app.writeJSON(w, http.StatusAccepted, env, nil)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) listAuthenticationTokensHandler(w http.ResponseWriter, r *http.Request){
+	user := app.contextGetUser(r)
+
+	// with jwt only the refresh token is in the database so a
+	// session is a refresh token and the current one is found by family
+	scope := data.ScopeAuthentication
+	currentFamily := ""
+	if app.config.auth.mode == authModeJWT{
+		scope = data.ScopeRefresh
+		if claims, err := app.jwtKeys.Verify(app.contextGetToken(r), time.Now()); err == nil{
+			currentFamily = claims.Family
+		}
+	}
+
+	tokens, err := app.models.Tokens.GetAllForUser(scope, user.ID)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// we never send the hash back, the current flag tell
+	// client which session is the one making this request
+	type session struct{
+		CreatedAt time.Time `json:"created_at"`
+		LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+		Expiry time.Time `json:"expiry"`
+		UserAgent string `json:"user_agent"`
+		IP string `json:"ip"`
+		Current bool `json:"current"`
+	}
+
+	currentHash := sha256.Sum256([]byte(app.contextGetToken(r)))
+
+	sessions := []session{}
+	for _, token := range tokens{
+		sessions = append(sessions, session{
+			CreatedAt: token.CreatedAt,
+			LastUsedAt: token.LastUsedAt,
+			Expiry: token.Expiry,
+			UserAgent: token.UserAgent,
+			IP: token.IP,
+			Current: bytes.Equal(token.Hash, currentHash[:]) || (currentFamily != "" && token.Family == currentFamily),
+		})
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"sessions": sessions}, nil)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteAuthenticationTokenHandler(w http.ResponseWriter, r *http.Request){
+	user := app.contextGetUser(r)
+	tokenPlaintext := app.contextGetToken(r)
+
+	if app.config.auth.mode == authModeJWT && jwt.LooksLikeJWT(tokenPlaintext){
+		claims, err := app.jwtKeys.Verify(tokenPlaintext, time.Now())
+		if err != nil{
+			app.invalidAuthenticationTokenResponse(w, r)
+			return
+		}
+
+		err = app.denyJWT(claims)
+		if err != nil{
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		err = app.models.Tokens.DeleteFamily(claims.Family)
+		if err != nil{
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		err = app.writeJSON(w, http.StatusOK, envelope{"message": "you have been logged out"}, nil)
+		if err != nil{
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// refresh token of this login is removed too so it can't bring the session back
+	err := app.models.Tokens.DeleteSession(user.ID, tokenPlaintext)
+	if err != nil{
+		switch{
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.invalidAuthenticationTokenResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "you have been logged out"}, nil)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteAllAuthenticationTokensHandler(w http.ResponseWriter, r *http.Request){
+	user := app.contextGetUser(r)
+
+	for _, scope := range []string{data.ScopeAuthentication, data.ScopeRefresh}{
+		err := app.models.Tokens.DeletAllForUser(scope, user.ID)
+		if err != nil{
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	err := app.denyAllJWTForUser(user.ID)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "all of your sessions have been logged out"}, nil)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) refreshAuthenticationTokenHandler(w http.ResponseWriter, r *http.Request){
+
+	var input struct{
+		RefreshToken string `json:"refresh_token"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil{
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	if data.ValidateTokenPlaintext(v, input.RefreshToken); !v.Valid(){
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	token, err := app.models.Tokens.UseRefresh(input.RefreshToken)
+	if err != nil{
+		switch{
+		case errors.Is(err, data.ErrTokenReused):
+			// a refresh token used twice mean someone else has a copy of it,
+			// we can't know who is real so revoke the whole family
+			err = app.models.Tokens.DeleteFamily(token.Family)
+			if err != nil{
+				app.serverErrorResponse(w, r, err)
+				return
+			}
+
+			app.logger.PrintInfo("refresh token reuse detected", map[string]string{
+				"user_id": strconv.FormatInt(token.UserID, 10),
+				"ip": app.clientIP(r),
+			})
+			app.refreshTokenReusedResponse(w, r)
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.invalidAuthenticationTokenResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	user, err := app.models.Users.Get(token.UserID)
+	if err != nil{
+		switch{
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.invalidAuthenticationTokenResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	env, err := app.issueAuthenticationTokens(r, user, token.Family, token.OrgID)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, env, nil)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+	}
 }
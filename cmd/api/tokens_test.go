@@ -0,0 +1,21 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// the limiter is checked before the user is read so a exhausted bucket
+// never reach the mock models
+func TestPasswordResetRateLimitedByEmail(t *testing.T){
+	app := newTestApplication(t)
+	app.config.limiter.emailEnabled = true
+	app.passwordResetLimiter = newKeyRateLimiter(rate.Every(time.Hour), 0)
+	defer app.passwordResetLimiter.stop()
+
+	status := executeRequest(t, app, http.MethodPost, "/v1/tokens/password-reset", "", `{"email": "alice@example.com"}`)
+	assertStatus(t, status, http.StatusTooManyRequests)
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"greenlight/internal/data"
+	"greenlight/internal/policy"
+	"greenlight/internal/validator"
+)
+
+// the deleted movies of the active organization, moderators see every
+// movie in the trash and other users only the movies they created
+func (app *application) listTrashedMoviesHandler(w http.ResponseWriter, r *http.Request){
+	var input struct{
+		data.Filters
+	}
+
+	v := validator.New()
+
+	qs := r.URL.Query()
+
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+
+	input.Filters.Sort = app.readStirng(qs, "sort", "-deleted_at")
+	input.Filters.SortSafelist = []string{"id", "title", "deleted_at", "-id", "-title", "-deleted_at"}
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid(){
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	orgID, ok := app.activeOrganization(w, r)
+	if !ok{
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	// requirePermission has put the permissions in the context
+	var createdBy *int64
+	permissions, _ := app.contextGetPermissions(r)
+	if !permissions.Allows("movies:moderate"){
+		createdBy = &user.ID
+	}
+
+	movies, metadata, err := app.models.Movies.GetAllTrashed(createdBy, orgID, input.Filters)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"movies": movies, "metadata": metadata}, nil)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// take a movie out of the trash, same rules as deleting it
+func (app *application) restoreMovieHandler(w http.ResponseWriter, r *http.Request){
+	id, err := app.readIDParam(r)
+	if err != nil{
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	orgID, ok := app.activeOrganization(w, r)
+	if !ok{
+		return
+	}
+
+	movie, err := app.models.Movies.GetTrashed(id, orgID)
+	if err != nil{
+		switch{
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.authorize(r, policy.MovieWrite, movie)
+	if err != nil{
+		switch{
+		case errors.Is(err, policy.ErrForbidden):
+			app.notPermittedResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.models.Movies.Restore(movie)
+	if err != nil{
+		switch{
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"movie": movie}, nil)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// delete a movie in the trash for good, this can't be undone
+func (app *application) purgeMovieHandler(w http.ResponseWriter, r *http.Request){
+	id, err := app.readIDParam(r)
+	if err != nil{
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	orgID, ok := app.activeOrganization(w, r)
+	if !ok{
+		return
+	}
+
+	movie, err := app.models.Movies.GetTrashed(id, orgID)
+	if err != nil{
+		switch{
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	entry := app.auditEntry(r, "movie.purged", "movie", movie.ID, map[string]any{"title": movie.Title})
+
+	err = app.models.Movies.Purge(movie.ID, orgID, entry)
+	if err != nil{
+		switch{
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "movie successfully purged"}, nil)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// purge the movies that have been in the trash longer than the retention
+// every interval until app.sweeperDone is closed. the goroutine is in app.wg
+// so shutdown wait for a purge which is running, it must be stopped before
+// app.wg.Wait is called. purge is Movies.PurgeDeletedBefore
+func (app *application) sweepTrash(interval time.Duration, purge func(cutoff time.Time) (int64, error)){
+	app.wg.Add(1)
+
+	go func(){
+		defer app.wg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for{
+			select{
+			case <-app.sweeperDone:
+				return
+			case <-ticker.C:
+			}
+
+			cutoff := time.Now().Add(-app.config.trash.retention)
+
+			purged, err := purge(cutoff)
+			if err != nil{
+				app.logger.PrintError(err, nil)
+				continue
+			}
+
+			if purged > 0{
+				app.logger.PrintInfo("trash swept", map[string]string{
+					"purged": strconv.FormatInt(purged, 10),
+				})
+			}
+		}
+	}()
+}
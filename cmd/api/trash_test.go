@@ -0,0 +1,108 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestTrashRoutesRequireWritePermission(t *testing.T){
+	app := newTestApplication(t)
+	readerToken := newTestToken(t, app, 1, "movies:read")
+
+	tests := []struct{
+		name string
+		method string
+		path string
+	}{
+		{"list", http.MethodGet, "/v1/trash/movies"},
+		{"restore", http.MethodPost, "/v1/movies/1/restore"},
+	}
+
+	for _, tt := range tests{
+		t.Run(tt.name, func(t *testing.T){
+			status := executeRequest(t, app, tt.method, tt.path, readerToken, "")
+			assertStatus(t, status, http.StatusForbidden)
+		})
+	}
+}
+
+func TestTrashRoutesRequireAuthentication(t *testing.T){
+	app := newTestApplication(t)
+
+	tests := []struct{
+		name string
+		method string
+		path string
+	}{
+		{"list", http.MethodGet, "/v1/trash/movies"},
+		{"restore", http.MethodPost, "/v1/movies/1/restore"},
+		{"purge", http.MethodDelete, "/v1/trash/movies/1"},
+	}
+
+	for _, tt := range tests{
+		t.Run(tt.name, func(t *testing.T){
+			status := executeRequest(t, app, tt.method, tt.path, "", "")
+			assertStatus(t, status, http.StatusUnauthorized)
+		})
+	}
+}
+
+func TestSweepTrashPurgesAfterRetention(t *testing.T){
+	app := newTestApplication(t)
+	app.config.trash.retention = 30 * 24 * time.Hour
+
+	cutoffs := make(chan time.Time, 1)
+
+	purge := func(cutoff time.Time) (int64, error){
+		select{
+		case cutoffs <- cutoff:
+		default:
+		}
+		return 1, nil
+	}
+
+	app.sweepTrash(10*time.Millisecond, purge)
+	defer func(){
+		close(app.sweeperDone)
+		app.wg.Wait()
+	}()
+
+	select{
+	case cutoff := <-cutoffs:
+		want := time.Now().Add(-app.config.trash.retention)
+		if cutoff.After(want) || want.Sub(cutoff) > time.Second{
+			t.Errorf("got cutoff %s; want about %s", cutoff, want)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the sweeper never purged")
+	}
+}
+
+// shutdown close sweeperDone then wait for app.wg, the sweeper must be out
+// of it by then
+func TestSweepTrashStopsOnShutdown(t *testing.T){
+	app := newTestApplication(t)
+	app.config.trash.retention = time.Hour
+
+	purge := func(cutoff time.Time) (int64, error){
+		return 0, nil
+	}
+
+	app.sweepTrash(time.Millisecond, purge)
+
+	time.Sleep(10*time.Millisecond)
+	close(app.sweeperDone)
+
+	done := make(chan struct{})
+	go func(){
+		app.wg.Wait()
+		close(done)
+	}()
+
+	select{
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("the sweeper did not stop")
+	}
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"greenlight/internal/data"
+	"greenlight/internal/totp"
+	"greenlight/internal/validator"
+)
+
+func (app *application) enableTwoFactorHandler(w http.ResponseWriter, r *http.Request){
+
+	// in jwt mode the user in context does not have the email
+	user, ok := app.currentUser(w, r)
+	if !ok{
+		return
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	err = app.models.TwoFactor.InsertPending(user.ID, secret)
+	if err != nil{
+		switch{
+		case errors.Is(err, data.ErrEditConflict):
+			v.AddError("2fa", "two-factor authentication is already enabled")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	env := envelope{
+		"secret": secret,
+		"otpauth_uri": totp.URI("Greenlight", user.Email, secret),
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, env, nil)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) confirmTwoFactorHandler(w http.ResponseWriter, r *http.Request){
+	user := app.contextGetUser(r)
+
+	var input struct{
+		Code string `json:"code"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil{
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	if data.ValidateTOTPCode(v, input.Code); !v.Valid(){
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	twoFactor, err := app.models.TwoFactor.Get(user.ID)
+	if err != nil{
+		switch{
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("2fa", "two-factor setup has not been started")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if twoFactor.Confirmed{
+		v.AddError("2fa", "two-factor authentication is already enabled")
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	step, ok := totp.Validate(twoFactor.Secret, input.Code, time.Now())
+	if !ok{
+		v.AddError("code", "invalid or expired code")
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.TwoFactor.UseStep(user.ID, step)
+	if err != nil{
+		switch{
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("code", "invalid or expired code")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	codes, err := app.models.TwoFactor.NewRecoveryCodes(user.ID)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// this is the only time the user can see the recovery codes
+	env := envelope{
+		"message": "two-factor authentication is enabled, store your recovery codes in a safe place",
+		"recovery_codes": codes,
+	}
+
+	err = app.writeJSON(w, http.StatusOK, env, nil)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// second step of the login, exchange the mfa-pending token and a code
+// (or a recovery code) for the real authentication token
+func (app *application) createMFAAuthenticationTokenHandler(w http.ResponseWriter, r *http.Request){
+
+	var input struct{
+		MFAToken string `json:"mfa_token"`
+		Code string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil{
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	data.ValidateTokenPlaintext(v, input.MFAToken)
+	v.Check(input.Code != "" || input.RecoveryCode != "", "code", "code or recovery_code must be provided")
+	if input.Code != ""{
+		data.ValidateTOTPCode(v, input.Code)
+	}
+
+	if !v.Valid(){
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	user, err := app.models.Users.GetForToken(data.ScopeMFAPending, input.MFAToken)
+	if err != nil{
+		switch{
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.invalidAuthenticationTokenResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// the pending token is single use, a wrong code mean the password has
+	// to be given again so the code can't be guessed with one login
+	err = app.models.Tokens.DeletAllForUser(data.ScopeMFAPending, user.ID)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if input.Code != ""{
+		twoFactor, err := app.models.TwoFactor.Get(user.ID)
+		if err != nil{
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		step, ok := totp.Validate(twoFactor.Secret, input.Code, time.Now())
+		if ok{
+			err = app.models.TwoFactor.UseStep(user.ID, step)
+			if err != nil && !errors.Is(err, data.ErrRecordNotFound){
+				app.serverErrorResponse(w, r, err)
+				return
+			}
+			// a code already used is not accepted again
+			ok = err == nil
+		}
+
+		if !ok{
+			app.invalidCredentialsResponse(w, r)
+			return
+		}
+	} else{
+		err = app.models.TwoFactor.UseRecoveryCode(user.ID, input.RecoveryCode)
+		if err != nil{
+			switch{
+			case errors.Is(err, data.ErrRecordNotFound):
+				app.invalidCredentialsResponse(w, r)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+	}
+
+	env, err := app.issueAuthenticationTokens(r, user, "", nil)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, env, nil)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+	}
+}
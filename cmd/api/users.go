@@ -3,6 +3,8 @@ package main
 import (
 	"errors"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"greenlight/internal/data"
@@ -10,11 +12,17 @@ import (
 )
 
 func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Request){
+
+	if app.config.registrationMode == registrationClosed{
+		app.registrationClosedResponse(w, r)
+		return
+	}
 	
 	var input struct{
 		Name string `json:"name"`
 		Email string `json:"email"`
 		Password string `json:"password"`
+		InvitationToken string `json:"invitation_token"`
 	}
 	err := app.readJSON(w, r, &input)
 	if err != nil{
@@ -41,18 +49,63 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	err = app.models.Users.Insert(user)
+	// in invite mode the token is required, an invited user already proved
+	// they own the email so the account is activated right away
+	var invitation *data.Invitation
+
+	if app.config.registrationMode == registrationInvite || input.InvitationToken != ""{
+		if v.Check(input.InvitationToken != "", "invitation_token", "must be provided"); !v.Valid(){
+			app.failedValidationResponse(w, r, v.Errors)
+			return
+		}
+
+		invitation, err = app.models.Invitations.GetForToken(input.InvitationToken)
+		if err != nil{
+			switch{
+			case errors.Is(err, data.ErrRecordNotFound):
+				v.AddError("invitation_token", "invalid or expired invitation token")
+				app.failedValidationResponse(w, r, v.Errors)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+
+		if validateInvitation(v, invitation, user.Email); !v.Valid(){
+			app.failedValidationResponse(w, r, v.Errors)
+			return
+		}
+
+		user.Activated = true
+
+		// the invitation is used up and the user get the default permissions
+		// in the same transaction as the insert
+		err = app.models.Users.InsertInvited(user, invitation, app.config.defaultPermissions)
+	}else{
+		err = app.models.Users.Insert(user)
+	}
 	if err != nil{
 		switch{
 		case errors.Is(err, data.ErrDuplicateEmail):
 			v.AddError("email", "a user with this email address already exists")
 			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("invitation_token", "invalid or expired invitation token")
+			app.failedValidationResponse(w, r, v.Errors)
 		default:
 			app.serverErrorResponse(w, r, err)
 		}
 		return
 	}
 
+	if invitation != nil{
+		err = app.writeJSON(w, http.StatusCreated, envelope{"user": user}, nil)
+		if err != nil{
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
 	token, err := app.models.Tokens.New(user.ID, 3*24*time.Hour, data.ScopeActivation)
 	if err != nil{
 		app.serverErrorResponse(w, r, err)
@@ -131,6 +184,459 @@ func (app *application) activateUserHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	err = app.addDefaultPermissions(user.ID)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"user": user}, nil)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// the invitation must not be expired and must have been sent to the email
+// the user register with
+func validateInvitation(v *validator.Validator, invitation *data.Invitation, email string){
+	v.Check(invitation.Expiry.After(time.Now()), "invitation_token", "invalid or expired invitation token")
+	v.Check(strings.EqualFold(invitation.Email, email), "email", "must be the email address the invitation was sent to")
+}
+
+// permissions every activated user get
+func (app *application) addDefaultPermissions(userID int64) error{
+	if len(app.config.defaultPermissions) == 0{
+		return nil
+	}
+
+	return app.models.Permissions.AddForUser(userID, app.config.defaultPermissions...)
+}
+
+func (app *application) updateUserPasswordHandler(w http.ResponseWriter, r *http.Request){
+	var input struct{
+		Password string `json:"password"`
+		TokenPlaintext string `json:"token"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil{
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	data.ValidatePasswordPlainText(v, input.Password)
+	data.ValidateTokenPlaintext(v, input.TokenPlaintext)
+
+	if !v.Valid(){
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	user, err := app.models.Users.GetForToken(data.ScopePasswordReset, input.TokenPlaintext)
+	if err != nil{
+		switch{
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("token", "invalid or expired password reset token")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = user.Password.Set(input.Password)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.models.Users.Update(user)
+	if err != nil{
+		switch{
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// password is changed so reset token and any other token
+	// the user have (like old login session) should not work anymore
+	err = app.models.Tokens.DeleteAllScopesForUser(user.ID)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.denyAllJWTForUser(user.ID)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// the owner proved they have the email so the account can be unlocked
+	err = app.models.LoginFailures.Reset(data.LoginFailureAccount, strings.ToLower(user.Email))
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "your password was successfully reset"}, nil)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// the user in the context can be a partial one when the auth mode is jwt,
+// so the /v1/users/me handlers always read the account from the database
+func (app *application) currentUser(w http.ResponseWriter, r *http.Request) (*data.User, bool){
+	user, err := app.models.Users.Get(app.contextGetUser(r).ID)
+	if err != nil{
+		switch{
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.invalidAuthenticationTokenResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+
+	return user, true
+}
+
+// the user as returned by /v1/users/me, with the version the client send
+// back in X-Expected-Version. other endpoints don't show it
+func currentUserJSON(user *data.User) any{
+	return struct{
+		*data.User
+		Version int `json:"version"`
+	}{user, user.Version}
+}
+
+func (app *application) showCurrentUserHandler(w http.ResponseWriter, r *http.Request){
+	user, ok := app.currentUser(w, r)
+	if !ok{
+		return
+	}
+
+	err := app.writeJSON(w, http.StatusOK, envelope{"user": currentUserJSON(user)}, nil)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) updateCurrentUserHandler(w http.ResponseWriter, r *http.Request){
+	user, ok := app.currentUser(w, r)
+	if !ok{
+		return
+	}
+
+	// same as for movies, the client can send the version it read
+	if r.Header.Get("X-Expected-Version") != ""{
+		if strconv.Itoa(user.Version) != r.Header.Get("X-Expected-Version"){
+			app.editConflictResponse(w, r)
+			return
+		}
+	}
+
+	var input struct{
+		Name *string `json:"name"`
+		Password *string `json:"password"`
+		CurrentPassword string `json:"current_password"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil{
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	if input.Name != nil{
+		user.Name = *input.Name
+	}
+
+	if input.Password != nil{
+		v.Check(input.CurrentPassword != "", "current_password", "must be provided to change the password")
+		if !v.Valid(){
+			app.failedValidationResponse(w, r, v.Errors)
+			return
+		}
+
+		match, err := user.Password.Matches(input.CurrentPassword)
+		if err != nil{
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		if !match{
+			v.AddError("current_password", "is incorrect")
+			app.failedValidationResponse(w, r, v.Errors)
+			return
+		}
+
+		err = user.Password.Set(*input.Password)
+		if err != nil{
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	if data.ValidateUser(v, user); !v.Valid(){
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Users.Update(user)
+	if err != nil{
+		switch{
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// a new password log out every session, like a password reset
+	if input.Password != nil{
+		err = app.models.Tokens.DeleteAllScopesForUser(user.ID)
+		if err != nil{
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		err = app.denyAllJWTForUser(user.ID)
+		if err != nil{
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"user": currentUserJSON(user)}, nil)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// deleting the account need the password again, a stolen token is not enough
+func (app *application) deleteCurrentUserHandler(w http.ResponseWriter, r *http.Request){
+	user, ok := app.currentUser(w, r)
+	if !ok{
+		return
+	}
+
+	var input struct{
+		Password string `json:"password"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil{
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	if v.Check(input.Password != "", "password", "must be provided"); !v.Valid(){
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	match, err := user.Password.Matches(input.Password)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if !match{
+		app.invalidCredentialsResponse(w, r)
+		return
+	}
+
+	// jwt are not in the database so they have to be denied before the user is gone
+	err = app.denyAllJWTForUser(user.ID)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// same hard erasure as for a data subject request
+	err = app.models.Users.Erase(user, nil)
+	if err != nil{
+		switch{
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "your account was successfully deleted"}, nil)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// the new address only replace the current one once the token mailed to it
+// is sent back to PUT /v1/users/email
+func (app *application) updateCurrentUserEmailHandler(w http.ResponseWriter, r *http.Request){
+	user, ok := app.currentUser(w, r)
+	if !ok{
+		return
+	}
+
+	var input struct{
+		Email string `json:"email"`
+		Password string `json:"password"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil{
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	data.ValidateEmail(v, input.Email)
+	v.Check(input.Password != "", "password", "must be provided")
+	v.Check(!strings.EqualFold(input.Email, user.Email), "email", "must be different from the current email")
+
+	if !v.Valid(){
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	match, err := user.Password.Matches(input.Password)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if !match{
+		app.invalidCredentialsResponse(w, r)
+		return
+	}
+
+	// checked again when the email is swapped, this is only to fail early
+	_, err = app.models.Users.GetByEmail(input.Email)
+	switch{
+	case err == nil:
+		v.AddError("email", "a user with this email address already exists")
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	case !errors.Is(err, data.ErrRecordNotFound):
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.models.Users.SetPendingEmail(user.ID, input.Email)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// a token sent for a previous pending email must not confirm this one
+	err = app.models.Tokens.DeletAllForUser(data.ScopeEmailChange, user.ID)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	token, err := app.models.Tokens.New(user.ID, 24*time.Hour, data.ScopeEmailChange)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.backgroud(func(){
+		data := map[string]any{
+			"emailChangeToken": token.Plaintext,
+		}
+
+		err = app.mailer.Send(input.Email, "token_email_change.tmpl", data)
+		if err != nil{
+			app.logger.PrintError(err, nil)
+		}
+	})
+
+	env := envelope{"message": "an email will be sent to the new address containing confirmation instructions"}
+
+	err = app.writeJSON(w, http.StatusAccepted, env, nil)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) confirmUserEmailHandler(w http.ResponseWriter, r *http.Request){
+	var input struct{
+		TokenPlaintext string `json:"token"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil{
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	if data.ValidateTokenPlaintext(v, input.TokenPlaintext); !v.Valid(){
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	user, err := app.models.Users.GetForToken(data.ScopeEmailChange, input.TokenPlaintext)
+	if err != nil{
+		switch{
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("token", "invalid or expired email change token")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	oldEmail, err := app.models.Users.ConfirmPendingEmail(user)
+	if err != nil{
+		switch{
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("token", "invalid or expired email change token")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrDuplicateEmail):
+			v.AddError("email", "a user with this email address already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.models.Tokens.DeletAllForUser(data.ScopeEmailChange, user.ID)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// tell the old address so the owner notice if the account was taken over
+	app.backgroud(func(){
+		data := map[string]any{
+			"newEmail": user.Email,
+		}
+
+		err = app.mailer.Send(oldEmail, "email_changed.tmpl", data)
+		if err != nil{
+			app.logger.PrintError(err, nil)
+		}
+	})
+
 	err = app.writeJSON(w, http.StatusOK, envelope{"user": user}, nil)
 	if err != nil{
 		app.serverErrorResponse(w, r, err)
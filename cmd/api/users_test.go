@@ -0,0 +1,61 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"greenlight/internal/data"
+	"greenlight/internal/validator"
+)
+
+func TestRegisterUserClosedRegistration(t *testing.T){
+	app := newTestApplication(t)
+	app.config.registrationMode = registrationClosed
+
+	body := `{"name": "Alice", "email": "alice@example.com", "password": "pa55word1234"}`
+
+	status := executeRequest(t, app, http.MethodPost, "/v1/users", "", body)
+	assertStatus(t, status, http.StatusForbidden)
+}
+
+func TestRegisterUserInviteRequireToken(t *testing.T){
+	app := newTestApplication(t)
+	app.config.registrationMode = registrationInvite
+
+	body := `{"name": "Alice", "email": "alice@example.com", "password": "pa55word1234"}`
+
+	status := executeRequest(t, app, http.MethodPost, "/v1/users", "", body)
+	assertStatus(t, status, http.StatusUnprocessableEntity)
+}
+
+func TestValidateInvitation(t *testing.T){
+	tests := []struct{
+		name string
+		email string
+		expiry time.Time
+		wantErrors []string
+	}{
+		{"valid", "Alice@Example.com", time.Now().Add(time.Hour), nil},
+		{"wrong email", "bob@example.com", time.Now().Add(time.Hour), []string{"email"}},
+		{"expired", "alice@example.com", time.Now().Add(-time.Minute), []string{"invitation_token"}},
+	}
+
+	for _, tt := range tests{
+		t.Run(tt.name, func(t *testing.T){
+			invitation := &data.Invitation{Email: "alice@example.com", Expiry: tt.expiry}
+
+			v := validator.New()
+			validateInvitation(v, invitation, tt.email)
+
+			if len(v.Errors) != len(tt.wantErrors){
+				t.Fatalf("got errors %v; want errors on %v", v.Errors, tt.wantErrors)
+			}
+			for _, key := range tt.wantErrors{
+				if _, ok := v.Errors[key]; !ok{
+					t.Errorf("missing error on %q in %v", key, v.Errors)
+				}
+			}
+		})
+	}
+}
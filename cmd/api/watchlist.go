@@ -0,0 +1,233 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"greenlight/internal/data"
+	"greenlight/internal/validator"
+)
+
+// set the watched flag, marking a movie watched without a date use now
+// and unwatching it clear the date
+func setWatched(item *data.WatchlistItem, watched *bool, watchedAt *time.Time){
+	if watched != nil{
+		item.Watched = *watched
+		if !item.Watched{
+			item.WatchedAt = nil
+		}
+	}
+
+	if watchedAt != nil{
+		item.WatchedAt = watchedAt
+	}
+
+	if item.Watched && item.WatchedAt == nil{
+		now := time.Now()
+		item.WatchedAt = &now
+	}
+}
+
+func (app *application) listWatchlistHandler(w http.ResponseWriter, r *http.Request){
+	user := app.contextGetUser(r)
+
+	var input struct{
+		Watched *bool
+		data.Filters
+	}
+
+	v := validator.New()
+
+	qs := r.URL.Query()
+
+	input.Watched = app.readBool(qs, "watched", v)
+
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+
+	input.Filters.Sort = app.readStirng(qs, "sort", "-added_at")
+	input.Filters.SortSafelist = []string{"added_at", "watched_at", "title", "year", "-added_at", "-watched_at", "-title", "-year"}
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid(){
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	items, metadata, err := app.models.Watchlist.GetAllForUser(user.ID, input.Watched, input.Filters)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"watchlist": items, "metadata": metadata}, nil)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) addWatchlistItemHandler(w http.ResponseWriter, r *http.Request){
+	user := app.contextGetUser(r)
+
+	var input struct{
+		MovieID int64 `json:"movie_id"`
+		Watched *bool `json:"watched"`
+		WatchedAt *time.Time `json:"watched_at"`
+		Notes string `json:"notes"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil{
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	movie, ok := app.readMovieInput(w, r, v, input.MovieID)
+	if !ok{
+		return
+	}
+
+	item := &data.WatchlistItem{
+		Movie: movie,
+		Notes: input.Notes,
+	}
+	setWatched(item, input.Watched, input.WatchedAt)
+
+	if data.ValidateWatchlistItem(v, item); !v.Valid(){
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Watchlist.Insert(user.ID, item)
+	if err != nil{
+		switch{
+		case errors.Is(err, data.ErrDuplicateWatchlistItem):
+			app.watchlistItemExistsResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"item": item}, nil)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) updateWatchlistItemHandler(w http.ResponseWriter, r *http.Request){
+	user := app.contextGetUser(r)
+
+	movieID, err := app.readIDParam(r)
+	if err != nil{
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	item, err := app.models.Watchlist.Get(user.ID, movieID)
+	if err != nil{
+		switch{
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	var input struct{
+		Watched *bool `json:"watched"`
+		WatchedAt *time.Time `json:"watched_at"`
+		Notes *string `json:"notes"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil{
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	setWatched(item, input.Watched, input.WatchedAt)
+	if input.Notes != nil{
+		item.Notes = *input.Notes
+	}
+
+	v := validator.New()
+
+	if data.ValidateWatchlistItem(v, item); !v.Valid(){
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Watchlist.Update(user.ID, item)
+	if err != nil{
+		switch{
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"item": item}, nil)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteWatchlistItemHandler(w http.ResponseWriter, r *http.Request){
+	user := app.contextGetUser(r)
+
+	movieID, err := app.readIDParam(r)
+	if err != nil{
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.models.Watchlist.Delete(user.ID, movieID)
+	if err != nil{
+		switch{
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "movie successfully removed from the watchlist"}, nil)
+	if err != nil{
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// load a movie given by id in a request body, only movies of the active
+// organization can be added. when ok is false the response was already sent
+func (app *application) readMovieInput(w http.ResponseWriter, r *http.Request, v *validator.Validator, movieID int64) (*data.Movie, bool){
+	if v.Check(movieID > 0, "movie_id", "must be provided"); !v.Valid(){
+		app.failedValidationResponse(w, r, v.Errors)
+		return nil, false
+	}
+
+	orgID, ok := app.activeOrganization(w, r)
+	if !ok{
+		return nil, false
+	}
+
+	movie, err := app.models.Movies.Get(movieID, orgID)
+	if err != nil{
+		switch{
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("movie_id", "must be an existing movie")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+
+	return movie, true
+}
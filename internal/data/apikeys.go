@@ -0,0 +1,221 @@
+package data
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"strings"
+	"time"
+
+	"greenlight/internal/validator"
+
+	"github.com/lib/pq"
+)
+
+// every api key start with this so it can't be mixed up with a token
+const APIKeyPrefix = "glk_"
+
+type APIKey struct{
+	ID int64 `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	Name string `json:"name"`
+	// first characters of the key so the user can recognize it
+	Prefix string `json:"prefix"`
+	Plaintext string `json:"key,omitempty"`
+	Hash []byte `json:"-"`
+	UserID int64 `json:"-"`
+	// when empty the key has every permission of the user
+	Permissions Permissions `json:"permissions"`
+	Expiry *time.Time `json:"expiry,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+func IsAPIKey(plaintext string) bool{
+	return strings.HasPrefix(plaintext, APIKeyPrefix)
+}
+
+func ValidateAPIKey(v *validator.Validator, key *APIKey){
+	v.Check(key.Name != "", "name", "must be provided")
+	v.Check(len(key.Name) <= 100, "name", "must not be more than 100 bytes long")
+
+	v.Check(validator.Unique(key.Permissions), "permissions", "must not contain duplicate values")
+
+	if key.Expiry != nil{
+		v.Check(key.Expiry.After(time.Now()), "expiry", "must be in the future")
+	}
+}
+
+func ValidateAPIKeyPlaintext(v *validator.Validator, plaintext string){
+	v.Check(IsAPIKey(plaintext), "key", "must start with "+APIKeyPrefix)
+	v.Check(len(plaintext) == len(APIKeyPrefix)+52, "key", "must be 56 bytes long")
+}
+
+type APIKeyModel struct{
+	DB *sql.DB
+}
+
+// generate the key, save the hash and fill key.Plaintext which
+// is the only time the plaintext exists
+func (m APIKeyModel) Insert(key *APIKey) error{
+	randomBytes := make([]byte, 32)
+
+	_, err := rand.Read(randomBytes)
+	if err != nil{
+		return err
+	}
+
+	key.Plaintext = APIKeyPrefix + base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(randomBytes)
+	key.Prefix = key.Plaintext[:len(APIKeyPrefix)+6]
+
+	hash := sha256.Sum256([]byte(key.Plaintext))
+	key.Hash = hash[:]
+
+	if key.Permissions == nil{
+		key.Permissions = Permissions{}
+	}
+
+	query := `INSERT INTO api_keys (user_id, name, prefix, hash, permissions, expiry)
+	VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, created_at`
+
+	args := []any{key.UserID, key.Name, key.Prefix, key.Hash, pq.Array(key.Permissions), key.Expiry}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(&key.ID, &key.CreatedAt)
+}
+
+func (m APIKeyModel) GetAllForUser(userID int64) ([]*APIKey, error){
+	query := `SELECT id, created_at, name, prefix, user_id, permissions, expiry, last_used_at
+	FROM api_keys WHERE user_id = $1 ORDER BY id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID)
+	if err != nil{
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	keys := []*APIKey{}
+
+	for rows.Next(){
+		var key APIKey
+
+		err := rows.Scan(
+			&key.ID,
+			&key.CreatedAt,
+			&key.Name,
+			&key.Prefix,
+			&key.UserID,
+			pq.Array(&key.Permissions),
+			&key.Expiry,
+			&key.LastUsedAt,
+		)
+		if err != nil{
+			return nil, err
+		}
+
+		keys = append(keys, &key)
+	}
+	if err = rows.Err(); err != nil{
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+// return the key and its user when the key exists and is not expired
+func (m APIKeyModel) GetForPlaintext(plaintext string) (*APIKey, *User, error){
+	hash := sha256.Sum256([]byte(plaintext))
+
+	query := `
+	SELECT api_keys.id, api_keys.created_at, api_keys.name, api_keys.prefix, api_keys.user_id,
+	api_keys.permissions, api_keys.expiry, api_keys.last_used_at,
+	users.id, users.created_at, users.name, users.email, users.password_hash, users.activated, users.version
+	FROM api_keys
+	INNER JOIN users ON users.id = api_keys.user_id
+	WHERE api_keys.hash = $1
+	AND (api_keys.expiry IS NULL OR api_keys.expiry > $2)`
+
+	var key APIKey
+	var user User
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, hash[:], time.Now()).Scan(
+		&key.ID,
+		&key.CreatedAt,
+		&key.Name,
+		&key.Prefix,
+		&key.UserID,
+		pq.Array(&key.Permissions),
+		&key.Expiry,
+		&key.LastUsedAt,
+		&user.ID,
+		&user.CreateAt,
+		&user.Name,
+		&user.Email,
+		&user.Password.hash,
+		&user.Activated,
+		&user.Version,
+	)
+	if err != nil{
+		switch{
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, nil, ErrRecordNotFound
+		default:
+			return nil, nil, err
+		}
+	}
+
+	return &key, &user, nil
+}
+
+// update the last time the key was used, like tokens the row is only
+// written when the stored time is older than lastUsedInterval
+func (m APIKeyModel) UpdateLastUsed(id int64) error{
+	query := `
+	UPDATE api_keys SET last_used_at = NOW()
+	WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < $2)`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, id, time.Now().Add(-lastUsedInterval))
+	return err
+}
+
+// delete the key if it belong to the user
+func (m APIKeyModel) Delete(id, userID int64) error{
+	if id < 1{
+		return ErrRecordNotFound
+	}
+
+	query := `DELETE FROM api_keys WHERE id = $1 AND user_id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id, userID)
+	if err != nil{
+		return err
+	}
+
+	rowAffected, err := result.RowsAffected()
+	if err != nil{
+		return err
+	}
+
+	if rowAffected == 0{
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,78 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+type AuditEntry struct{
+	ID int64 `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	ActorID *int64 `json:"actor_id"`
+	Action string `json:"action"`
+	TargetType string `json:"target_type"`
+	TargetID int64 `json:"target_id"`
+	Details map[string]any `json:"details"`
+	IP string `json:"ip"`
+}
+
+type AuditModel struct{
+	DB *sql.DB
+}
+
+func (m AuditModel) Insert(entry *AuditEntry) error{
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return insertAudit(ctx, m.DB, entry)
+}
+
+// run fn in a transaction and write the audit entry in the same one, so
+// the change and its record are committed together or not at all. with a
+// nil entry it is only a transaction
+func withAudit(ctx context.Context, db *sql.DB, entry *AuditEntry, fn func(tx *sql.Tx) error) error{
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil{
+		return err
+	}
+	defer tx.Rollback()
+
+	err = fn(tx)
+	if err != nil{
+		return err
+	}
+
+	if entry != nil{
+		err = insertAudit(ctx, tx, entry)
+		if err != nil{
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// *sql.DB and *sql.Tx, so the entry can be written in or out of a transaction
+type queryRower interface{
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func insertAudit(ctx context.Context, q queryRower, entry *AuditEntry) error{
+	details := entry.Details
+	if details == nil{
+		details = map[string]any{}
+	}
+
+	detailsJSON, err := json.Marshal(details)
+	if err != nil{
+		return err
+	}
+
+	query := `INSERT INTO audit_log (actor_id, action, target_type, target_id, details, ip) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, created_at`
+
+	args := []any{entry.ActorID, entry.Action, entry.TargetType, entry.TargetID, detailsJSON, entry.IP}
+
+	return q.QueryRowContext(ctx, query, args...).Scan(&entry.ID, &entry.CreatedAt)
+}
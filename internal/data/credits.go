@@ -0,0 +1,197 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"greenlight/internal/validator"
+)
+
+var ErrDuplicateCredit = errors.New("duplicate credit")
+
+// the roles a person can be credited for
+var CreditRoles = []string{"actor", "director", "writer", "producer", "composer", "cinematographer", "editor"}
+
+type Credit struct{
+	ID int64 `json:"id"`
+	MovieID int64 `json:"movie_id"`
+	PersonID int64 `json:"person_id"`
+	// filled when the credits are read, not used for writes
+	PersonName string `json:"person_name,omitempty"`
+	Role string `json:"role"`
+	Character string `json:"character,omitempty"`
+	BillingOrder int `json:"billing_order"`
+	Version int32 `json:"version"`
+}
+
+func ValidateCredit(v *validator.Validator, credit *Credit){
+	v.Check(credit.PersonID > 0, "person_id", "must be provided")
+
+	v.Check(credit.Role != "", "role", "must be provided")
+	v.Check(validator.PermittedValue(credit.Role, CreditRoles...), "role", "invalid role value")
+
+	v.Check(credit.Character == "" || credit.Role == "actor", "character", "must only be provided for actors")
+	v.Check(len(credit.Character) <= 500, "character", "must not be more than 500 bytes long")
+
+	v.Check(credit.BillingOrder >= 0, "billing_order", "must not be negative")
+}
+
+type CreditModel struct{
+	DB *sql.DB
+}
+
+func (m CreditModel) Insert(credit *Credit) error{
+	query := `INSERT INTO movie_credits (movie_id, person_id, role, character, billing_order)
+	VALUES ($1, $2, $3, $4, $5) RETURNING id, version`
+
+	args := []any{credit.MovieID, credit.PersonID, credit.Role, credit.Character, credit.BillingOrder}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&credit.ID, &credit.Version)
+	if err != nil{
+		switch{
+		case err.Error() == `pq: duplicate key value violates unique constraint "movie_credits_unique"`:
+			return ErrDuplicateCredit
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+func (m CreditModel) Get(id, movieID int64) (*Credit, error){
+	if id < 1{
+		return nil, ErrRecordNotFound
+	}
+
+	query := `SELECT movie_credits.id, movie_credits.movie_id, movie_credits.person_id, people.name, movie_credits.role, movie_credits.character, movie_credits.billing_order, movie_credits.version
+	FROM movie_credits
+	INNER JOIN people ON people.id = movie_credits.person_id
+	WHERE movie_credits.id = $1 AND movie_credits.movie_id = $2`
+
+	var credit Credit
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id, movieID).Scan(
+		&credit.ID,
+		&credit.MovieID,
+		&credit.PersonID,
+		&credit.PersonName,
+		&credit.Role,
+		&credit.Character,
+		&credit.BillingOrder,
+		&credit.Version,
+	)
+	if err != nil{
+		switch{
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &credit, nil
+}
+
+// the cast and crew of a movie in billing order
+func (m CreditModel) GetAllForMovie(movieID int64) ([]*Credit, error){
+	query := `SELECT movie_credits.id, movie_credits.movie_id, movie_credits.person_id, people.name, movie_credits.role, movie_credits.character, movie_credits.billing_order, movie_credits.version
+	FROM movie_credits
+	INNER JOIN people ON people.id = movie_credits.person_id
+	WHERE movie_credits.movie_id = $1
+	ORDER BY movie_credits.billing_order, movie_credits.id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, movieID)
+	if err != nil{
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	credits := []*Credit{}
+
+	for rows.Next(){
+		var credit Credit
+
+		err := rows.Scan(
+			&credit.ID,
+			&credit.MovieID,
+			&credit.PersonID,
+			&credit.PersonName,
+			&credit.Role,
+			&credit.Character,
+			&credit.BillingOrder,
+			&credit.Version,
+		)
+		if err != nil{
+			return nil, err
+		}
+
+		credits = append(credits, &credit)
+	}
+	if err = rows.Err(); err != nil{
+		return nil, err
+	}
+
+	return credits, nil
+}
+
+func (m CreditModel) Update(credit *Credit) error{
+	query := `UPDATE movie_credits SET role = $1, character = $2, billing_order = $3, version = version + 1
+	WHERE id = $4 AND movie_id = $5 AND version = $6 RETURNING version`
+
+	args := []any{credit.Role, credit.Character, credit.BillingOrder, credit.ID, credit.MovieID, credit.Version}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&credit.Version)
+	if err != nil{
+		switch{
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		case err.Error() == `pq: duplicate key value violates unique constraint "movie_credits_unique"`:
+			return ErrDuplicateCredit
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+func (m CreditModel) Delete(id, movieID int64) error{
+	if id < 1{
+		return ErrRecordNotFound
+	}
+
+	query := `DELETE FROM movie_credits WHERE id = $1 AND movie_id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id, movieID)
+	if err != nil{
+		return err
+	}
+
+	rowAffected, err := result.RowsAffected()
+	if err != nil{
+		return err
+	}
+
+	if rowAffected == 0{
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,42 @@
+package data
+
+import (
+	"testing"
+
+	"greenlight/internal/validator"
+)
+
+func TestValidateCredit(t *testing.T){
+	tests := []struct{
+		name string
+		credit Credit
+		field string
+	}{
+		{"director", Credit{PersonID: 1, Role: "director"}, ""},
+		{"actor with character", Credit{PersonID: 1, Role: "actor", Character: "Moana", BillingOrder: 1}, ""},
+		{"no person", Credit{Role: "director"}, "person_id"},
+		{"no role", Credit{PersonID: 1}, "role"},
+		{"unknown role", Credit{PersonID: 1, Role: "stunt"}, "role"},
+		{"character for a director", Credit{PersonID: 1, Role: "director", Character: "Moana"}, "character"},
+		{"character too long", Credit{PersonID: 1, Role: "actor", Character: string(make([]byte, 501))}, "character"},
+		{"negative billing order", Credit{PersonID: 1, Role: "actor", BillingOrder: -1}, "billing_order"},
+	}
+
+	for _, tt := range tests{
+		t.Run(tt.name, func(t *testing.T){
+			v := validator.New()
+			ValidateCredit(v, &tt.credit)
+
+			if tt.field == ""{
+				if !v.Valid(){
+					t.Errorf("got errors %v; want none", v.Errors)
+				}
+				return
+			}
+
+			if _, ok := v.Errors[tt.field]; !ok{
+				t.Errorf("got errors %v; want one for %q", v.Errors, tt.field)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// DeniedToken is a revoked jwt, when JTI is empty every token of
+// the user issued before RevokedAt is revoked. RevokedAt is set by the
+// api, with the same clock as the iat of the tokens, NOW() is used when
+// it is zero
+type DeniedToken struct{
+	JTI string
+	UserID int64
+	RevokedAt time.Time
+	Expiry time.Time
+}
+
+type DenylistModel struct{
+	DB *sql.DB
+}
+
+func (m DenylistModel) Insert(token *DeniedToken) error{
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return insertDeniedToken(ctx, m.DB, token)
+}
+
+func insertDeniedToken(ctx context.Context, q queryRower, token *DeniedToken) error{
+	query := `INSERT INTO token_denylist (jti, user_id, revoked_at, expiry) VALUES ($1, $2, COALESCE($3, NOW()), $4) RETURNING revoked_at`
+
+	var revokedAt *time.Time
+	if !token.RevokedAt.IsZero(){
+		revokedAt = &token.RevokedAt
+	}
+
+	args := []any{token.JTI, token.UserID, revokedAt, token.Expiry}
+
+	return q.QueryRowContext(ctx, query, args...).Scan(&token.RevokedAt)
+}
+
+// return every entry which is not expired, the list stay small because
+// entry only live as long as the token it revoke
+func (m DenylistModel) GetAllActive() ([]*DeniedToken, error){
+	query := `SELECT jti, user_id, revoked_at, expiry FROM token_denylist WHERE expiry > $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, time.Now())
+	if err != nil{
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	tokens := []*DeniedToken{}
+
+	for rows.Next(){
+		var token DeniedToken
+
+		err := rows.Scan(&token.JTI, &token.UserID, &token.RevokedAt, &token.Expiry)
+		if err != nil{
+			return nil, err
+		}
+
+		tokens = append(tokens, &token)
+	}
+	if err = rows.Err(); err != nil{
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+func (m DenylistModel) DeleteExpired() error{
+	query := `DELETE FROM token_denylist WHERE expiry <= $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, time.Now())
+	return err
+}
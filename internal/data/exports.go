@@ -0,0 +1,68 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+type ExportModel struct{
+	DB *sql.DB
+}
+
+// save the archive of the user, a new export replace the previous one
+func (m ExportModel) Insert(userID int64, archive []byte, expiry time.Time) error{
+	query := `INSERT INTO user_exports (user_id, archive, expiry) VALUES ($1, $2, $3)
+	ON CONFLICT (user_id) DO UPDATE SET archive = EXCLUDED.archive, expiry = EXCLUDED.expiry, created_at = NOW()`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, userID, archive, expiry)
+	return err
+}
+
+func (m ExportModel) Get(userID int64) (json.RawMessage, error){
+	query := `SELECT archive FROM user_exports WHERE user_id = $1 AND expiry > $2`
+
+	var archive []byte
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, userID, time.Now()).Scan(&archive)
+	if err != nil{
+		switch{
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return archive, nil
+}
+
+// when the last export of the user was built, expired or not
+func (m ExportModel) CreatedAt(userID int64) (time.Time, error){
+	query := `SELECT created_at FROM user_exports WHERE user_id = $1`
+
+	var createdAt time.Time
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, userID).Scan(&createdAt)
+	if err != nil{
+		switch{
+		case errors.Is(err, sql.ErrNoRows):
+			return time.Time{}, ErrRecordNotFound
+		default:
+			return time.Time{}, err
+		}
+	}
+
+	return createdAt, nil
+}
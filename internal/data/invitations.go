@@ -0,0 +1,102 @@
+package data
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+type Invitation struct{
+	ID int64 `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	Email string `json:"email"`
+	InvitedBy *int64 `json:"invited_by,omitempty"`
+	Expiry time.Time `json:"expiry"`
+	// only set when the invitation is created, it is mailed and never shown
+	Plaintext string `json:"-"`
+}
+
+type InvitationModel struct{
+	DB *sql.DB
+}
+
+// create the invitation and its token, inviting the same email again
+// replace the previous invitation so the old token stop working
+// entry is the audit record of the invitation, its target is set to the
+// invitation id. it can be nil
+func (m InvitationModel) Insert(invitation *Invitation, ttl time.Duration, entry *AuditEntry) error{
+	token, err := genrateToken(0, ttl, "invitation")
+	if err != nil{
+		return err
+	}
+
+	query := `INSERT INTO invitations (email, hash, invited_by, expiry) VALUES ($1, $2, $3, $4)
+	ON CONFLICT (email) DO UPDATE SET hash = EXCLUDED.hash, invited_by = EXCLUDED.invited_by, expiry = EXCLUDED.expiry, created_at = NOW()
+	RETURNING id, created_at`
+
+	args := []any{invitation.Email, token.Hash, invitation.InvitedBy, token.Expiry}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err = withAudit(ctx, m.DB, entry, func(tx *sql.Tx) error{
+		err := tx.QueryRowContext(ctx, query, args...).Scan(&invitation.ID, &invitation.CreatedAt)
+		if err != nil{
+			return err
+		}
+
+		if entry != nil{
+			entry.TargetID = invitation.ID
+		}
+		return nil
+	})
+	if err != nil{
+		return err
+	}
+
+	invitation.Expiry = token.Expiry
+	invitation.Plaintext = token.Plaintext
+
+	return nil
+}
+
+func (m InvitationModel) GetForToken(tokenPlaintext string) (*Invitation, error){
+	tokenHash := sha256.Sum256([]byte(tokenPlaintext))
+
+	query := `SELECT id, created_at, email, invited_by, expiry FROM invitations WHERE hash = $1 AND expiry > $2`
+
+	var invitation Invitation
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, tokenHash[:], time.Now()).Scan(
+		&invitation.ID,
+		&invitation.CreatedAt,
+		&invitation.Email,
+		&invitation.InvitedBy,
+		&invitation.Expiry,
+	)
+	if err != nil{
+		switch{
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &invitation, nil
+}
+
+func (m InvitationModel) Delete(id int64) error{
+	query := `DELETE FROM invitations WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, id)
+	return err
+}
@@ -0,0 +1,368 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"greenlight/internal/validator"
+)
+
+type List struct{
+	ID int64 `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UserID int64 `json:"user_id"`
+	Name string `json:"name"`
+	Description string `json:"description"`
+	Public bool `json:"public"`
+	Version int `json:"version"`
+}
+
+// the user who created the list
+func (l *List) OwnerID() (int64, bool){
+	return l.UserID, true
+}
+
+// Position is the rank of the item among the ones the reader can see, the
+// position stored in lists_items only give the order and can have gaps
+type ListItem struct{
+	Position int `json:"position"`
+	AddedAt time.Time `json:"added_at"`
+	Movie *Movie `json:"movie"`
+}
+
+func ValidateList(v *validator.Validator, list *List){
+	v.Check(list.Name != "", "name", "must be provided")
+	v.Check(len(list.Name) <= 100, "name", "must not be more than 100 bytes long")
+	v.Check(len(list.Description) <= 2000, "description", "must not be more than 2000 bytes long")
+}
+
+type ListModel struct{
+	DB *sql.DB
+}
+
+func (m ListModel) Insert(list *List) error{
+	query := `INSERT INTO lists (user_id, name, description, public) VALUES ($1, $2, $3, $4) RETURNING id, created_at, version`
+
+	args := []any{list.UserID, list.Name, list.Description, list.Public}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(&list.ID, &list.CreatedAt, &list.Version)
+}
+
+func (m ListModel) Get(id int64) (*List, error){
+	if id < 1{
+		return nil, ErrRecordNotFound
+	}
+
+	query := `SELECT id, created_at, user_id, name, description, public, version FROM lists WHERE id = $1`
+
+	var list List
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+		&list.ID,
+		&list.CreatedAt,
+		&list.UserID,
+		&list.Name,
+		&list.Description,
+		&list.Public,
+		&list.Version,
+	)
+	if err != nil{
+		switch{
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &list, nil
+}
+
+func (m ListModel) GetAllForUser(userID int64) ([]*List, error){
+	query := `SELECT id, created_at, user_id, name, description, public, version FROM lists WHERE user_id = $1 ORDER BY id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID)
+	if err != nil{
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	lists := []*List{}
+
+	for rows.Next(){
+		var list List
+
+		err := rows.Scan(
+			&list.ID,
+			&list.CreatedAt,
+			&list.UserID,
+			&list.Name,
+			&list.Description,
+			&list.Public,
+			&list.Version,
+		)
+		if err != nil{
+			return nil, err
+		}
+
+		lists = append(lists, &list)
+	}
+	if err = rows.Err(); err != nil{
+		return nil, err
+	}
+
+	return lists, nil
+}
+
+func (m ListModel) Update(list *List) error{
+	query := `UPDATE lists SET name = $1, description = $2, public = $3, version = version + 1
+	WHERE id = $4 AND version = $5 RETURNING version`
+
+	args := []any{list.Name, list.Description, list.Public, list.ID, list.Version}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&list.Version)
+	if err != nil{
+		switch{
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m ListModel) Delete(id int64) error{
+	query := `DELETE FROM lists WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil{
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil{
+		return err
+	}
+
+	if rowsAffected == 0{
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// every item of the list, with the movies in the trash, used for the data export
+func (m ListModel) GetAllItems(listID int64) ([]*ListItem, error){
+	query := `SELECT ROW_NUMBER() OVER (ORDER BY lists_items.position, movies.id), lists_items.added_at, ` + movieColumns + `
+	FROM lists_items
+	INNER JOIN movies ON movies.id = lists_items.movie_id
+	WHERE lists_items.list_id = $1
+	ORDER BY lists_items.position ASC, movies.id ASC`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, listID)
+	if err != nil{
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	items := []*ListItem{}
+
+	for rows.Next(){
+		item := ListItem{Movie: &Movie{}}
+
+		dest := append([]any{&item.Position, &item.AddedAt}, movieScanDest(item.Movie)...)
+
+		err := rows.Scan(dest...)
+		if err != nil{
+			return nil, err
+		}
+
+		items = append(items, &item)
+	}
+	if err = rows.Err(); err != nil{
+		return nil, err
+	}
+
+	return items, nil
+}
+
+// the movies a reader see in a list, the ones in the trash are left out and
+// so are the private movies of a organization which is not orgID
+const visibleListItems = `FROM lists_items
+	INNER JOIN movies ON movies.id = lists_items.movie_id
+	WHERE lists_items.list_id = $1 AND movies.deleted_at IS NULL AND (movies.org_id IS NULL OR movies.org_id = $2)`
+
+// the items the reader see, orgID is there active organization
+func (m ListModel) GetItems(listID int64, orgID *int64, filters Filters) ([]*ListItem, MetaData, error){
+	query := `SELECT COUNT(*) OVER(), ROW_NUMBER() OVER (ORDER BY lists_items.position, movies.id), lists_items.added_at, ` + movieColumns + `
+	` + visibleListItems + `
+	ORDER BY lists_items.position ASC, movies.id ASC LIMIT $3 OFFSET $4`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, listID, orgID, filters.limit(), filters.offset())
+	if err != nil{
+		return nil, MetaData{}, err
+	}
+
+	defer rows.Close()
+
+	totalRecords := 0
+	items := []*ListItem{}
+
+	for rows.Next(){
+		item := ListItem{Movie: &Movie{}}
+
+		dest := append([]any{&totalRecords, &item.Position, &item.AddedAt}, movieScanDest(item.Movie)...)
+
+		err := rows.Scan(dest...)
+		if err != nil{
+			return nil, MetaData{}, err
+		}
+
+		items = append(items, &item)
+	}
+	if err = rows.Err(); err != nil{
+		return nil, MetaData{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return items, metadata, nil
+}
+
+// put the movie at the position (1 is the first), a movie already in the
+// list is moved. position nil or after the end add it at the end. position
+// is the one GetItems return for the same orgID, so the hidden movies are
+// not counted. the list row is locked so two changes can't mix there shifts
+func (m ListModel) SetItem(listID, movieID int64, orgID *int64, position *int) (int, error){
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil{
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var id int64
+
+	err = tx.QueryRowContext(ctx, `SELECT id FROM lists WHERE id = $1 FOR UPDATE`, listID).Scan(&id)
+	if err != nil{
+		switch{
+		case errors.Is(err, sql.ErrNoRows):
+			return 0, ErrRecordNotFound
+		default:
+			return 0, err
+		}
+	}
+
+	err = removeListItem(ctx, tx, listID, movieID)
+	if err != nil && !errors.Is(err, ErrRecordNotFound){
+		return 0, err
+	}
+
+	var count int
+
+	err = tx.QueryRowContext(ctx, `SELECT COUNT(*) `+visibleListItems, listID, orgID).Scan(&count)
+	if err != nil{
+		return 0, err
+	}
+
+	// the movie take the stored position of the visible item it goes before,
+	// or the one after the last item of the list
+	var stored int
+
+	newPosition := count + 1
+	if position != nil && *position >= 1 && *position <= count{
+		newPosition = *position
+
+		query := `SELECT lists_items.position ` + visibleListItems + `
+		ORDER BY lists_items.position ASC, movies.id ASC OFFSET $3 LIMIT 1`
+
+		err = tx.QueryRowContext(ctx, query, listID, orgID, newPosition-1).Scan(&stored)
+	} else{
+		err = tx.QueryRowContext(ctx, `SELECT COALESCE(MAX(position), 0) + 1 FROM lists_items WHERE list_id = $1`, listID).Scan(&stored)
+	}
+	if err != nil{
+		return 0, err
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE lists_items SET position = position + 1 WHERE list_id = $1 AND position >= $2`, listID, stored)
+	if err != nil{
+		return 0, err
+	}
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO lists_items (list_id, movie_id, position) VALUES ($1, $2, $3)`, listID, movieID, stored)
+	if err != nil{
+		return 0, err
+	}
+
+	return newPosition, tx.Commit()
+}
+
+func (m ListModel) RemoveItem(listID, movieID int64) error{
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil{
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `SELECT id FROM lists WHERE id = $1 FOR UPDATE`, listID)
+	if err != nil{
+		return err
+	}
+
+	err = removeListItem(ctx, tx, listID, movieID)
+	if err != nil{
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// delete the item and move the items after it up by one
+func removeListItem(ctx context.Context, tx *sql.Tx, listID, movieID int64) error{
+	var position int
+
+	err := tx.QueryRowContext(ctx, `DELETE FROM lists_items WHERE list_id = $1 AND movie_id = $2 RETURNING position`, listID, movieID).Scan(&position)
+	if err != nil{
+		switch{
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE lists_items SET position = position - 1 WHERE list_id = $1 AND position > $2`, listID, position)
+	return err
+}
@@ -0,0 +1,105 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+const (
+	LoginFailureAccount = "account"
+	LoginFailureIP = "ip"
+)
+
+type LoginFailure struct{
+	Kind string
+	Key string
+	Failures int
+	LastFailureAt time.Time
+	LockedUntil *time.Time
+}
+
+// return true when the key is locked at t
+func (l *LoginFailure) Locked(t time.Time) bool{
+	return l.LockedUntil != nil && l.LockedUntil.After(t)
+}
+
+type LoginFailureModel struct{
+	DB *sql.DB
+}
+
+func (m LoginFailureModel) Get(kind, key string) (*LoginFailure, error){
+	query := `SELECT kind, key, failures, last_failure_at, locked_until FROM login_failures WHERE kind = $1 AND key = $2`
+
+	var failure LoginFailure
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, kind, key).Scan(
+		&failure.Kind,
+		&failure.Key,
+		&failure.Failures,
+		&failure.LastFailureAt,
+		&failure.LockedUntil,
+	)
+	if err != nil{
+		switch{
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &failure, nil
+}
+
+// add one failure for the key, when the last failure is older than window
+// the count start again from one
+func (m LoginFailureModel) RecordFailure(kind, key string, window time.Duration) (*LoginFailure, error){
+	query := `INSERT INTO login_failures (kind, key, failures) VALUES ($1, $2, 1)
+	ON CONFLICT (kind, key) DO UPDATE SET
+		failures = CASE WHEN login_failures.last_failure_at < $3 THEN 1 ELSE login_failures.failures + 1 END,
+		last_failure_at = NOW()
+	RETURNING kind, key, failures, last_failure_at, locked_until`
+
+	var failure LoginFailure
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, kind, key, time.Now().Add(-window)).Scan(
+		&failure.Kind,
+		&failure.Key,
+		&failure.Failures,
+		&failure.LastFailureAt,
+		&failure.LockedUntil,
+	)
+	if err != nil{
+		return nil, err
+	}
+
+	return &failure, nil
+}
+
+func (m LoginFailureModel) Lock(kind, key string, until time.Time) error{
+	query := `UPDATE login_failures SET locked_until = $1 WHERE kind = $2 AND key = $3`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, until, kind, key)
+	return err
+}
+
+func (m LoginFailureModel) Reset(kind, key string) error{
+	query := `DELETE FROM login_failures WHERE kind = $1 AND key = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, kind, key)
+	return err
+}
@@ -15,6 +15,23 @@ type Models struct{
 	Users UserModel
 	Tokens TokenModel
 	Permissions PermissionModel
+	Denylist DenylistModel
+	TwoFactor TwoFactorModel
+	LoginFailures LoginFailureModel
+	Roles RoleModel
+	APIKeys APIKeyModel
+	OAuthClients OAuthClientModel
+	OAuthCodes OAuthCodeModel
+	Audit AuditModel
+	Exports ExportModel
+	Invitations InvitationModel
+	Organizations OrganizationModel
+	Reviews ReviewModel
+	Watchlist WatchlistModel
+	Lists ListModel
+	People PersonModel
+	Credits CreditModel
+	Revisions RevisionModel
 }
 
 func NewModels(db *sql.DB) Models{
@@ -23,6 +40,23 @@ func NewModels(db *sql.DB) Models{
 		Users: UserModel{DB: db},
 		Tokens: TokenModel{DB: db},
 		Permissions: PermissionModel{DB: db},
+		Denylist: DenylistModel{DB: db},
+		TwoFactor: TwoFactorModel{DB: db},
+		LoginFailures: LoginFailureModel{DB: db},
+		Roles: RoleModel{DB: db},
+		APIKeys: APIKeyModel{DB: db},
+		OAuthClients: OAuthClientModel{DB: db},
+		OAuthCodes: OAuthCodeModel{DB: db},
+		Audit: AuditModel{DB: db},
+		Exports: ExportModel{DB: db},
+		Invitations: InvitationModel{DB: db},
+		Organizations: OrganizationModel{DB: db},
+		Reviews: ReviewModel{DB: db},
+		Watchlist: WatchlistModel{DB: db},
+		Lists: ListModel{DB: db},
+		People: PersonModel{DB: db},
+		Credits: CreditModel{DB: db},
+		Revisions: RevisionModel{DB: db},
 	}
 }
 
@@ -32,5 +66,22 @@ func NewMockModels() Models{
 		Users: UserModel{},
 		Tokens: TokenModel{},
 		Permissions: PermissionModel{},
+		Denylist: DenylistModel{},
+		TwoFactor: TwoFactorModel{},
+		LoginFailures: LoginFailureModel{},
+		Roles: RoleModel{},
+		APIKeys: APIKeyModel{},
+		OAuthClients: OAuthClientModel{},
+		OAuthCodes: OAuthCodeModel{},
+		Audit: AuditModel{},
+		Exports: ExportModel{},
+		Invitations: InvitationModel{},
+		Organizations: OrganizationModel{},
+		Reviews: ReviewModel{},
+		Watchlist: WatchlistModel{},
+		Lists: ListModel{},
+		People: PersonModel{},
+		Credits: CreditModel{},
+		Revisions: RevisionModel{},
 	}
 }
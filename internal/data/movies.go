@@ -24,9 +24,34 @@ type Movie struct{
 	Year int32	`json:"year,omitempty"`
 	Runtime Runtime `json:"runtime,omitempty"`// movie lenght
 	Genres []string `json:"genres,omitempty"`
+	CreatedBy *int64 `json:"created_by,omitempty"`
+	// organization the movie belong to, nil for the shared catalog
+	OrgID *int64 `json:"org_id,omitempty"`
+	// kept in sync with the reviews by ReviewModel and when a user is deleted
+	AverageRating float64 `json:"average_rating"`
+	ReviewCount int `json:"review_count"`
+	// set when the movie is in the trash
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
 	Version int32 `json:"version"`
 }
 
+// the user who created the movie, ok is false for movies
+// created before we started to record it
+func (m *Movie) OwnerID() (int64, bool){
+	if m.CreatedBy == nil{
+		return 0, false
+	}
+	return *m.CreatedBy, true
+}
+
+// the organization of a private movie, ok is false for the shared catalog
+func (m *Movie) OrganizationID() (int64, bool){
+	if m.OrgID == nil{
+		return 0, false
+	}
+	return *m.OrgID, true
+}
+
 func ValidateMovie(v *validator.Validator, movie *Movie){
 	v.Check(movie.Title != "", "title", "must be provided")
 	v.Check(len(movie.Title) <= 500, "title", "must not be more than 500 bytes long")
@@ -46,31 +71,68 @@ func ValidateMovie(v *validator.Validator, movie *Movie){
 
 }
 
+// the movie columns every query select, in the order of movieScanDest so
+// other models joining movies (watchlist, lists) scan them the same way
+const movieColumns = `movies.id, movies.created_at, movies.title, movies.year, movies.runtime, movies.genres, movies.created_by, movies.org_id, movies.average_rating, movies.review_count, movies.deleted_at, movies.version`
+
+func movieScanDest(movie *Movie) []any{
+	return []any{
+		&movie.ID,
+		&movie.CreatedAt,
+		&movie.Title,
+		&movie.Year,
+		&movie.Runtime,
+		pq.Array(&movie.Genres),
+		&movie.CreatedBy,
+		&movie.OrgID,
+		&movie.AverageRating,
+		&movie.ReviewCount,
+		&movie.DeletedAt,
+		&movie.Version,
+	}
+}
+
 func(m MovieModel) Insert(movie *Movie) error{
 	query := `
-		INSERT INTO movies (title, year, runtime, genres
-		) VALUES ($1, $2, $3, $4) RETURNING id, created_at, version`
+		INSERT INTO movies (title, year, runtime, genres, created_by, org_id
+		) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, created_at, version`
 	
 	// pq array is changing Array go array type into 
 	// psql type array
-	args := []any{movie.Title, movie.Year, movie.Runtime, pq.Array(movie.Genres)}
+	args := []any{movie.Title, movie.Year, movie.Runtime, pq.Array(movie.Genres), movie.CreatedBy, movie.OrgID}
 
 	// Create a context with a 3-second timeout.
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 	
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil{
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&movie.ID, &movie.CreatedAt, &movie.Version)
+	if err != nil{
+		return err
+	}
+
+	err = insertRevision(ctx, tx, movie, movie.CreatedBy)
+	if err != nil{
+		return err
+	}
 
-	return m.DB.QueryRowContext(ctx, query, args...).Scan(&movie.ID, &movie.CreatedAt, &movie.Version)
+	return tx.Commit()
 }
 
 // we are using int64 on uint because error
-func(m MovieModel) Get(id int64) (*Movie, error){
+// orgID is the active organization of the caller, a movie of another
+// organization is not found
+func(m MovieModel) Get(id int64, orgID *int64) (*Movie, error){
 	if id < 1 {
 		return nil, ErrRecordNotFound
 	}
-	query := `SELECT id, created_at, title, year,
-	runtime, genres, version FROM movies where id 
-	= $1`
+	query := `SELECT ` + movieColumns + ` FROM movies where id 
+	= $1 AND org_id IS NOT DISTINCT FROM $2 AND deleted_at IS NULL`
 
 	var movie Movie
 
@@ -84,15 +146,7 @@ func(m MovieModel) Get(id int64) (*Movie, error){
 	defer cancel()
 
 	//when listen for the signal then it terminate the query and return
-	err := m.DB.QueryRowContext(ctx, query, id).Scan(
-		&movie.ID,
-		&movie.CreatedAt,
-		&movie.Title,
-		&movie.Year,
-		&movie.Runtime,
-		pq.Array(&movie.Genres),
-		&movie.Version,
-		)
+	err := m.DB.QueryRowContext(ctx, query, id, orgID).Scan(movieScanDest(&movie)...)
 
 	if err != nil{
 		switch{
@@ -105,9 +159,10 @@ func(m MovieModel) Get(id int64) (*Movie, error){
 	return &movie, nil
 }
 
-func(m MovieModel) Update(movie *Movie) error{
+// editedBy is the user making the change, it is saved with the revision
+func(m MovieModel) Update(movie *Movie, editedBy int64) error{
 	query := `UPDATE movies set title = $1, year = 
-		$2, runtime = $3, genres = $4, version = version + 1 WHERE id = $5 AND version = $6 RETURNING version`
+		$2, runtime = $3, genres = $4, version = version + 1 WHERE id = $5 AND version = $6 AND org_id IS NOT DISTINCT FROM $7 AND deleted_at IS NULL RETURNING version`
 
 	args := []any{
 		movie.Title,
@@ -116,12 +171,19 @@ func(m MovieModel) Update(movie *Movie) error{
 		pq.Array(movie.Genres),
 		movie.ID,
 		movie.Version,
+		movie.OrgID,
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&movie.Version)
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil{
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&movie.Version)
 	if err != nil{
 		switch{
 		case errors.Is(err, sql.ErrNoRows):
@@ -130,22 +192,29 @@ func(m MovieModel) Update(movie *Movie) error{
 			return err
 		}
 	}
-	return nil
+
+	err = insertRevision(ctx, tx, movie, &editedBy)
+	if err != nil{
+		return err
+	}
+
+	return tx.Commit()
 }
 
-func(m MovieModel) Delete(id int64) error{
+// move the movie to the trash, it can be restored until it is purged
+func(m MovieModel) Delete(id int64, orgID *int64) error{
 
 	if id < 1{
 		return ErrRecordNotFound
 	}
-	query := `DELETE from movies WHERE id = $1`
+	query := `UPDATE movies SET deleted_at = NOW() WHERE id = $1 AND org_id IS NOT DISTINCT FROM $2 AND deleted_at IS NULL`
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	result, err := m.DB.ExecContext(ctx, query, id)
+	result, err := m.DB.ExecContext(ctx, query, id, orgID)
 	if err != nil{
-		return nil
+		return err
 	}
 
 	rowAffected, err := result.RowsAffected()
@@ -160,17 +229,19 @@ func(m MovieModel) Delete(id int64) error{
 	return nil
 }
 
-func (m MovieModel) GetAll(title string, genres []string, filters Filters) ([]*Movie, MetaData, error){
+// director and actor match the name of a person credited on the movie for
+// that role, empty mean no filter
+func (m MovieModel) GetAll(title string, genres []string, director, actor string, orgID *int64, filters Filters) ([]*Movie, MetaData, error){
 
 	// @> say if contian pq array 
 	// to_tsvector and plainto_tsquery is changing it title of movies and query
 	// into lexmes meaning "The Batman" into "the" "batman" lower and splitting matching it
-	query := fmt.Sprintf(`SELECT COUNT(*) OVER(), id, created_at, title, year, runtime, genres, version from movies WHERE (to_tsvector('simple', title) @@ plainto_tsquery('simple', $1) OR $1 = '') AND (genres @> $2 OR $2 = '{}') ORDER BY %s %s, id ASC LIMIT $3 OFFSET $4`, filters.sortColumn(), filters.sortDirection())
+	query := fmt.Sprintf(`SELECT COUNT(*) OVER(), `+movieColumns+` from movies WHERE (to_tsvector('simple', title) @@ plainto_tsquery('simple', $1) OR $1 = '') AND (genres @> $2 OR $2 = '{}') AND org_id IS NOT DISTINCT FROM $3 AND deleted_at IS NULL AND `+creditFilter("director", 6)+` AND `+creditFilter("actor", 7)+` ORDER BY %s %s, id ASC LIMIT $4 OFFSET $5`, filters.sortColumn(), filters.sortDirection())
 
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
 	defer cancel()
 
-	args := []any{title, pq.Array(genres), filters.limit(), filters.offset()}
+	args := []any{title, pq.Array(genres), orgID, filters.limit(), filters.offset(), director, actor}
 
 	rows, err := m.DB.QueryContext(ctx, query, args...)
 	if err != nil{
@@ -186,16 +257,7 @@ func (m MovieModel) GetAll(title string, genres []string, filters Filters) ([]*M
 		
 		var movie Movie
 
-		err := rows.Scan(
-			&totalRecords,
-			&movie.ID,
-			&movie.CreatedAt,
-			&movie.Title,
-			&movie.Year,
-			&movie.Runtime,
-			pq.Array(&movie.Genres),
-			&movie.Version,
-		)
+		err := rows.Scan(append([]any{&totalRecords}, movieScanDest(&movie)...)...)
 
 		if err != nil{
 			return nil, MetaData{}, err
@@ -212,24 +274,206 @@ func (m MovieModel) GetAll(title string, genres []string, filters Filters) ([]*M
 	return movies, metadata, nil
 }
 
+// start of a subquery for the credits of the movie, the conditions on the
+// role and the person name are added after
+const creditedQuery = `SELECT 1 FROM movie_credits INNER JOIN people ON people.id = movie_credits.person_id
+	WHERE movie_credits.movie_id = movies.id`
+
+// the condition of GetAll keeping the movies with a person credited for the
+// role whose name match the $param placeholder, an empty name keep them all.
+// role is one of CreditRoles, never a user input
+func creditFilter(role string, param int) string{
+	return fmt.Sprintf(`($%[2]d = '' OR EXISTS (%[3]s AND movie_credits.role = '%[1]s' AND to_tsvector('simple', people.name) @@ plainto_tsquery('simple', $%[2]d)))`, role, param, creditedQuery)
+}
+
+// every movie the user created, used for the data export
+func (m MovieModel) GetAllCreatedBy(userID int64) ([]*Movie, error){
+	query := `SELECT ` + movieColumns + ` FROM movies WHERE created_by = $1 ORDER BY id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID)
+	if err != nil{
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	movies := []*Movie{}
+
+	for rows.Next(){
+		var movie Movie
+
+		err := rows.Scan(movieScanDest(&movie)...)
+		if err != nil{
+			return nil, err
+		}
+
+		movies = append(movies, &movie)
+	}
+	if err = rows.Err(); err != nil{
+		return nil, err
+	}
+
+	return movies, nil
+}
+
+// same as Get but for a movie in the trash
+func (m MovieModel) GetTrashed(id int64, orgID *int64) (*Movie, error){
+	if id < 1{
+		return nil, ErrRecordNotFound
+	}
+
+	query := `SELECT ` + movieColumns + ` FROM movies WHERE id = $1 AND org_id IS NOT DISTINCT FROM $2 AND deleted_at IS NOT NULL`
+
+	var movie Movie
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id, orgID).Scan(movieScanDest(&movie)...)
+	if err != nil{
+		switch{
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &movie, nil
+}
+
+// the movies in the trash of the organization, createdBy limit them to the
+// movies of one user when not nil
+func (m MovieModel) GetAllTrashed(createdBy *int64, orgID *int64, filters Filters) ([]*Movie, MetaData, error){
+	query := fmt.Sprintf(`SELECT COUNT(*) OVER(), `+movieColumns+` FROM movies
+	WHERE deleted_at IS NOT NULL AND org_id IS NOT DISTINCT FROM $1 AND (created_by = $2 OR $2 IS NULL)
+	ORDER BY %s %s, id ASC LIMIT $3 OFFSET $4`, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, orgID, createdBy, filters.limit(), filters.offset())
+	if err != nil{
+		return nil, MetaData{}, err
+	}
+
+	defer rows.Close()
+
+	totalRecords := 0
+	movies := []*Movie{}
+
+	for rows.Next(){
+		var movie Movie
+
+		err := rows.Scan(append([]any{&totalRecords}, movieScanDest(&movie)...)...)
+		if err != nil{
+			return nil, MetaData{}, err
+		}
+
+		movies = append(movies, &movie)
+	}
+	if err = rows.Err(); err != nil{
+		return nil, MetaData{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return movies, metadata, nil
+}
+
+// take the movie out of the trash
+func (m MovieModel) Restore(movie *Movie) error{
+	query := `UPDATE movies SET deleted_at = NULL
+	WHERE id = $1 AND org_id IS NOT DISTINCT FROM $2 AND deleted_at IS NOT NULL`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, movie.ID, movie.OrgID)
+	if err != nil{
+		return err
+	}
+
+	rowAffected, err := result.RowsAffected()
+	if err != nil{
+		return err
+	}
+
+	if rowAffected == 0{
+		return ErrRecordNotFound
+	}
+
+	movie.DeletedAt = nil
+	return nil
+}
+
+// remove a movie in the trash for good, with everything that cascade from it.
+// entry is the audit record of the purge and can be nil
+func (m MovieModel) Purge(id int64, orgID *int64, entry *AuditEntry) error{
+	if id < 1{
+		return ErrRecordNotFound
+	}
+
+	query := `DELETE FROM movies WHERE id = $1 AND org_id IS NOT DISTINCT FROM $2 AND deleted_at IS NOT NULL`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return withAudit(ctx, m.DB, entry, func(tx *sql.Tx) error{
+		result, err := tx.ExecContext(ctx, query, id, orgID)
+		if err != nil{
+			return err
+		}
+
+		rowAffected, err := result.RowsAffected()
+		if err != nil{
+			return err
+		}
+
+		if rowAffected == 0{
+			return ErrRecordNotFound
+		}
+
+		return nil
+	})
+}
+
+// purge every movie that went to the trash before the cutoff and return how
+// many were removed, used by the trash sweeper
+func (m MovieModel) PurgeDeletedBefore(cutoff time.Time) (int64, error){
+	query := `DELETE FROM movies WHERE deleted_at IS NOT NULL AND deleted_at < $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, cutoff)
+	if err != nil{
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
 // Mock start here
 
 func(m MockMovieModel) Insert(movie *Movie) error{
 	return nil
 }
 
-func(m MockMovieModel) Get(id int64) (*Movie, error){
+func(m MockMovieModel) Get(id int64, orgID *int64) (*Movie, error){
 	return nil, nil
 }
 
-func(m MockMovieModel) Update(movie *Movie) error{
+func(m MockMovieModel) Update(movie *Movie, editedBy int64) error{
 	return nil
 }
 
-func(m MockMovieModel) Delete(id int64) error{
+func(m MockMovieModel) Delete(id int64, orgID *int64) error{
 	return nil
 }
 
-func (m MockMovieModel) GetAll(title string, genres []string, filters Filters) ([]*Movie, MetaData, error){
+func (m MockMovieModel) GetAll(title string, genres []string, director, actor string, orgID *int64, filters Filters) ([]*Movie, MetaData, error){
 	return nil, MetaData{}, nil
 }
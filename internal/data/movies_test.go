@@ -0,0 +1,51 @@
+package data
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCreditFilter(t *testing.T){
+	tests := []struct{
+		role string
+		param int
+		want []string
+	}{
+		{"director", 6, []string{"($6 = '' OR EXISTS (", "movie_credits.role = 'director'", "plainto_tsquery('simple', $6)"}},
+		{"actor", 7, []string{"($7 = '' OR EXISTS (", "movie_credits.role = 'actor'", "plainto_tsquery('simple', $7)"}},
+	}
+
+	for _, tt := range tests{
+		t.Run(tt.role, func(t *testing.T){
+			filter := creditFilter(tt.role, tt.param)
+
+			for _, want := range tt.want{
+				if !strings.Contains(filter, want){
+					t.Errorf("%q does not contain %q", filter, want)
+				}
+			}
+
+			// the subquery must be tied to the movie of the outer query
+			if !strings.Contains(filter, "movie_credits.movie_id = movies.id"){
+				t.Errorf("%q is not correlated with the movie", filter)
+			}
+
+			if strings.Count(filter, "(") != strings.Count(filter, ")"){
+				t.Errorf("%q has unbalanced parentheses", filter)
+			}
+		})
+	}
+}
+
+func TestCreditFilterRolesAreCreditRoles(t *testing.T){
+	// GetAll filter on these two roles, they must be roles a credit can have
+	for _, role := range []string{"director", "actor"}{
+		found := false
+		for _, creditRole := range CreditRoles{
+			found = found || creditRole == role
+		}
+		if !found{
+			t.Errorf("%q is not in CreditRoles", role)
+		}
+	}
+}
@@ -0,0 +1,295 @@
+package data
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"net/url"
+	"time"
+
+	"greenlight/internal/validator"
+
+	"github.com/lib/pq"
+)
+
+// OAuthClient is a third-party app registered by a user, oauth
+// scopes are the permission codes like movies:read
+type OAuthClient struct{
+	ID int64 `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	ClientID string `json:"client_id"`
+	Secret string `json:"client_secret,omitempty"`
+	SecretHash []byte `json:"-"`
+	UserID int64 `json:"-"`
+	Name string `json:"name"`
+	RedirectURIs []string `json:"redirect_uris"`
+	Scopes []string `json:"scopes"`
+	Confidential bool `json:"confidential"`
+}
+
+// check the secret in constant time, a public client never match
+func (c *OAuthClient) MatchesSecret(secret string) bool{
+	if c.SecretHash == nil || secret == ""{
+		return false
+	}
+	hash := sha256.Sum256([]byte(secret))
+	return subtle.ConstantTimeCompare(hash[:], c.SecretHash) == 1
+}
+
+func (c *OAuthClient) AllowsRedirectURI(uri string) bool{
+	return validator.PermittedValue(uri, c.RedirectURIs...)
+}
+
+func ValidateOAuthClient(v *validator.Validator, client *OAuthClient){
+	v.Check(client.Name != "", "name", "must be provided")
+	v.Check(len(client.Name) <= 200, "name", "must not be more than 200 bytes long")
+
+	v.Check(len(client.RedirectURIs) >= 1, "redirect_uris", "must contain at least 1 uri")
+	v.Check(len(client.RedirectURIs) <= 10, "redirect_uris", "must not contain more than 10 uris")
+	for _, uri := range client.RedirectURIs{
+		u, err := url.Parse(uri)
+		v.Check(err == nil && (u.Scheme == "https" || u.Scheme == "http") && u.Host != "" && u.Fragment == "", "redirect_uris", "must only contain absolute http or https uris without fragment")
+	}
+
+	v.Check(len(client.Scopes) >= 1, "scopes", "must contain at least 1 scope")
+	v.Check(validator.Unique(client.Scopes), "scopes", "must not contain duplicate values")
+}
+
+type OAuthClientModel struct{
+	DB *sql.DB
+}
+
+// create the client id and, for confidential client, the secret which
+// is only in plaintext in client.Secret after this call
+func (m OAuthClientModel) Insert(client *OAuthClient) error{
+	clientID, err := randomString(16)
+	if err != nil{
+		return err
+	}
+	client.ClientID = clientID
+
+	if client.Confidential{
+		client.Secret, err = randomString(32)
+		if err != nil{
+			return err
+		}
+		hash := sha256.Sum256([]byte(client.Secret))
+		client.SecretHash = hash[:]
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var known int
+	err = m.DB.QueryRowContext(ctx, `SELECT COUNT(*) FROM permissions WHERE code = ANY($1)`, pq.Array(client.Scopes)).Scan(&known)
+	if err != nil{
+		return err
+	}
+
+	if known != len(client.Scopes){
+		return ErrUnknownPermission
+	}
+
+	query := `INSERT INTO oauth_clients (client_id, secret_hash, user_id, name, redirect_uris, scopes)
+	VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, created_at`
+
+	args := []any{client.ClientID, client.SecretHash, client.UserID, client.Name, pq.Array(client.RedirectURIs), pq.Array(client.Scopes)}
+
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(&client.ID, &client.CreatedAt)
+}
+
+func (m OAuthClientModel) GetByClientID(clientID string) (*OAuthClient, error){
+	query := `SELECT id, created_at, client_id, secret_hash, user_id, name, redirect_uris, scopes
+	FROM oauth_clients WHERE client_id = $1`
+
+	var client OAuthClient
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, clientID).Scan(
+		&client.ID,
+		&client.CreatedAt,
+		&client.ClientID,
+		&client.SecretHash,
+		&client.UserID,
+		&client.Name,
+		pq.Array(&client.RedirectURIs),
+		pq.Array(&client.Scopes),
+	)
+	if err != nil{
+		switch{
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	client.Confidential = client.SecretHash != nil
+
+	return &client, nil
+}
+
+func (m OAuthClientModel) GetAllForUser(userID int64) ([]*OAuthClient, error){
+	query := `SELECT id, created_at, client_id, secret_hash, user_id, name, redirect_uris, scopes
+	FROM oauth_clients WHERE user_id = $1 ORDER BY id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID)
+	if err != nil{
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	clients := []*OAuthClient{}
+
+	for rows.Next(){
+		var client OAuthClient
+
+		err := rows.Scan(
+			&client.ID,
+			&client.CreatedAt,
+			&client.ClientID,
+			&client.SecretHash,
+			&client.UserID,
+			&client.Name,
+			pq.Array(&client.RedirectURIs),
+			pq.Array(&client.Scopes),
+		)
+		if err != nil{
+			return nil, err
+		}
+
+		client.Confidential = client.SecretHash != nil
+		clients = append(clients, &client)
+	}
+	if err = rows.Err(); err != nil{
+		return nil, err
+	}
+
+	return clients, nil
+}
+
+// delete the client of the user with the token issued to it
+func (m OAuthClientModel) Delete(id, userID int64) error{
+	if id < 1{
+		return ErrRecordNotFound
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil{
+		return err
+	}
+	defer tx.Rollback()
+
+	var clientID string
+	err = tx.QueryRowContext(ctx, `DELETE FROM oauth_clients WHERE id = $1 AND user_id = $2 RETURNING client_id`, id, userID).Scan(&clientID)
+	if err != nil{
+		switch{
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM tokens WHERE client_id = $1`, clientID)
+	if err != nil{
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// OAuthCode is a authorization code waiting to be exchanged for a token
+type OAuthCode struct{
+	ClientID string
+	UserID int64
+	RedirectURI string
+	Scopes []string
+	CodeChallenge string
+	Expiry time.Time
+}
+
+type OAuthCodeModel struct{
+	DB *sql.DB
+}
+
+// save the code and return its plaintext, it is made like any other token
+func (m OAuthCodeModel) New(code *OAuthCode, ttl time.Duration) (string, error){
+	token, err := genrateToken(code.UserID, ttl, "oauth-code")
+	if err != nil{
+		return "", err
+	}
+
+	code.Expiry = token.Expiry
+
+	query := `INSERT INTO oauth_codes (hash, client_id, user_id, redirect_uri, scopes, code_challenge, expiry)
+	VALUES ($1, $2, $3, $4, $5, $6, $7)`
+
+	args := []any{token.Hash, code.ClientID, code.UserID, code.RedirectURI, pq.Array(code.Scopes), code.CodeChallenge, code.Expiry}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err = m.DB.ExecContext(ctx, query, args...)
+	if err != nil{
+		return "", err
+	}
+
+	return token.Plaintext, nil
+}
+
+// delete the code and return it, so a code can only be used once
+func (m OAuthCodeModel) Consume(plaintext string) (*OAuthCode, error){
+	hash := sha256.Sum256([]byte(plaintext))
+
+	query := `DELETE FROM oauth_codes WHERE hash = $1 AND expiry > $2
+	RETURNING client_id, user_id, redirect_uri, scopes, code_challenge, expiry`
+
+	var code OAuthCode
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, hash[:], time.Now()).Scan(
+		&code.ClientID,
+		&code.UserID,
+		&code.RedirectURI,
+		pq.Array(&code.Scopes),
+		&code.CodeChallenge,
+		&code.Expiry,
+	)
+	if err != nil{
+		switch{
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &code, nil
+}
+
+func randomString(n int) (string, error){
+	randomBytes := make([]byte, n)
+
+	_, err := rand.Read(randomBytes)
+	if err != nil{
+		return "", err
+	}
+
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(randomBytes), nil
+}
@@ -0,0 +1,234 @@
+package data
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"time"
+
+	"greenlight/internal/validator"
+)
+
+// roles a user can have inside a organization. owner and admin manage the
+// members and moderate the private catalog of the organization, a member
+// can add movies to it and change the ones they created. purging the trash
+// still need the movies:purge permission
+const (
+	OrgRoleOwner = "owner"
+	OrgRoleAdmin = "admin"
+	OrgRoleMember = "member"
+)
+
+var ErrDuplicateMember = errors.New("duplicate member")
+
+type Organization struct{
+	ID int64 `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	Name string `json:"name"`
+	Version int `json:"version"`
+	// role of the user the organization was loaded for
+	Role string `json:"role,omitempty"`
+}
+
+type OrganizationMember struct{
+	OrgID int64 `json:"org_id"`
+	UserID int64 `json:"user_id"`
+	Role string `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// owner and admin can manage the members
+func (m *OrganizationMember) CanManage() bool{
+	return m.Role == OrgRoleOwner || m.Role == OrgRoleAdmin
+}
+
+func ValidateOrganization(v *validator.Validator, org *Organization){
+	v.Check(org.Name != "", "name", "must be provided")
+	v.Check(len(org.Name) <= 100, "name", "must not be more than 100 bytes long")
+}
+
+func ValidateOrgRole(v *validator.Validator, role string){
+	v.Check(validator.PermittedValue(role, OrgRoleOwner, OrgRoleAdmin, OrgRoleMember), "role", "must be owner, admin or member")
+}
+
+type OrganizationModel struct{
+	DB *sql.DB
+}
+
+// create the organization with the user as owner
+func (m OrganizationModel) Insert(org *Organization, ownerID int64) error{
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil{
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO organizations (name) VALUES ($1) RETURNING id, created_at, version`
+
+	err = tx.QueryRowContext(ctx, query, org.Name).Scan(&org.ID, &org.CreatedAt, &org.Version)
+	if err != nil{
+		return err
+	}
+
+	query = `INSERT INTO organizations_members (org_id, user_id, role) VALUES ($1, $2, $3)`
+
+	_, err = tx.ExecContext(ctx, query, org.ID, ownerID, OrgRoleOwner)
+	if err != nil{
+		return err
+	}
+
+	org.Role = OrgRoleOwner
+
+	return tx.Commit()
+}
+
+// every organization the user is a member of, with there role
+func (m OrganizationModel) GetAllForUser(userID int64) ([]*Organization, error){
+	query := `SELECT organizations.id, organizations.created_at, organizations.name, organizations.version, organizations_members.role
+	FROM organizations
+	INNER JOIN organizations_members ON organizations_members.org_id = organizations.id
+	WHERE organizations_members.user_id = $1
+	ORDER BY organizations.id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID)
+	if err != nil{
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	orgs := []*Organization{}
+
+	for rows.Next(){
+		var org Organization
+
+		err := rows.Scan(&org.ID, &org.CreatedAt, &org.Name, &org.Version, &org.Role)
+		if err != nil{
+			return nil, err
+		}
+
+		orgs = append(orgs, &org)
+	}
+	if err = rows.Err(); err != nil{
+		return nil, err
+	}
+
+	return orgs, nil
+}
+
+// ErrRecordNotFound when the user is not a member of the organization
+func (m OrganizationModel) GetMember(orgID, userID int64) (*OrganizationMember, error){
+	query := `SELECT org_id, user_id, role, created_at FROM organizations_members WHERE org_id = $1 AND user_id = $2`
+
+	var member OrganizationMember
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, orgID, userID).Scan(&member.OrgID, &member.UserID, &member.Role, &member.CreatedAt)
+	if err != nil{
+		switch{
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &member, nil
+}
+
+func addMember(ctx context.Context, q queryRower, member *OrganizationMember) error{
+	query := `INSERT INTO organizations_members (org_id, user_id, role) VALUES ($1, $2, $3) RETURNING created_at`
+
+	err := q.QueryRowContext(ctx, query, member.OrgID, member.UserID, member.Role).Scan(&member.CreatedAt)
+	if err != nil{
+		switch{
+		case err.Error() == `pq: duplicate key value violates unique constraint "organizations_members_pkey"`:
+			return ErrDuplicateMember
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// invite the user to join the organization with the role, the plaintext
+// token of the invitation is returned. ErrDuplicateMember is returned when
+// the user is already a member
+func (m OrganizationModel) Invite(orgID, userID int64, role string, invitedBy int64, ttl time.Duration) (string, error){
+	token, err := genrateToken(userID, ttl, "organization-invitation")
+	if err != nil{
+		return "", err
+	}
+
+	query := `
+	INSERT INTO organizations_invitations (org_id, user_id, role, hash, invited_by, expiry)
+	SELECT $1, $2, $3, $4, $5, $6
+	WHERE NOT EXISTS (SELECT 1 FROM organizations_members WHERE org_id = $1 AND user_id = $2)
+	ON CONFLICT (org_id, user_id) DO UPDATE SET role = EXCLUDED.role, hash = EXCLUDED.hash, invited_by = EXCLUDED.invited_by, expiry = EXCLUDED.expiry, created_at = NOW()
+	RETURNING id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var id int64
+	err = m.DB.QueryRowContext(ctx, query, orgID, userID, role, token.Hash, invitedBy, token.Expiry).Scan(&id)
+	if err != nil{
+		switch{
+		case errors.Is(err, sql.ErrNoRows):
+			return "", ErrDuplicateMember
+		default:
+			return "", err
+		}
+	}
+
+	return token.Plaintext, nil
+}
+
+// the user join the organization with the role of the invitation, which is
+// used up in the same transaction. ErrRecordNotFound is returned when the
+// token is not a invitation of the user to the organization or it expired
+func (m OrganizationModel) AcceptInvitation(orgID, userID int64, tokenPlaintext string) (*OrganizationMember, error){
+	tokenHash := sha256.Sum256([]byte(tokenPlaintext))
+
+	query := `
+	DELETE FROM organizations_invitations
+	WHERE org_id = $1 AND user_id = $2 AND hash = $3 AND expiry > $4
+	RETURNING role`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	member := &OrganizationMember{
+		OrgID: orgID,
+		UserID: userID,
+	}
+
+	err := withAudit(ctx, m.DB, nil, func(tx *sql.Tx) error{
+		err := tx.QueryRowContext(ctx, query, orgID, userID, tokenHash[:], time.Now()).Scan(&member.Role)
+		if err != nil{
+			switch{
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrRecordNotFound
+			default:
+				return err
+			}
+		}
+
+		return addMember(ctx, tx, member)
+	})
+	if err != nil{
+		return nil, err
+	}
+
+	return member, nil
+}
@@ -0,0 +1,236 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"greenlight/internal/validator"
+)
+
+var ErrPersonCredited = errors.New("person credited")
+
+type Person struct{
+	ID int64 `json:"id"`
+	CreatedAt time.Time `json:"-"`
+	Name string `json:"name"`
+	BirthYear *int32 `json:"birth_year,omitempty"`
+	Bio string `json:"bio,omitempty"`
+	CreatedBy *int64 `json:"created_by,omitempty"`
+	Version int32 `json:"version"`
+}
+
+// one line of the filmography of a person
+type FilmographyEntry struct{
+	CreditID int64 `json:"credit_id"`
+	Role string `json:"role"`
+	Character string `json:"character,omitempty"`
+	Movie *Movie `json:"movie"`
+}
+
+func ValidatePerson(v *validator.Validator, person *Person){
+	v.Check(person.Name != "", "name", "must be provided")
+	v.Check(len(person.Name) <= 500, "name", "must not be more than 500 bytes long")
+
+	if person.BirthYear != nil{
+		v.Check(*person.BirthYear >= 1800, "birth_year", "must be greater than 1800")
+		v.Check(*person.BirthYear <= int32(time.Now().Year()), "birth_year", "must no be in the future")
+	}
+
+	v.Check(len(person.Bio) <= 10_000, "bio", "must not be more than 10000 bytes long")
+}
+
+type PersonModel struct{
+	DB *sql.DB
+}
+
+func (m PersonModel) Insert(person *Person) error{
+	query := `INSERT INTO people (name, birth_year, bio, created_by) VALUES ($1, $2, $3, $4) RETURNING id, created_at, version`
+
+	args := []any{person.Name, person.BirthYear, person.Bio, person.CreatedBy}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(&person.ID, &person.CreatedAt, &person.Version)
+}
+
+func (m PersonModel) Get(id int64) (*Person, error){
+	if id < 1{
+		return nil, ErrRecordNotFound
+	}
+
+	query := `SELECT id, created_at, name, birth_year, bio, created_by, version FROM people WHERE id = $1`
+
+	var person Person
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+		&person.ID,
+		&person.CreatedAt,
+		&person.Name,
+		&person.BirthYear,
+		&person.Bio,
+		&person.CreatedBy,
+		&person.Version,
+	)
+	if err != nil{
+		switch{
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &person, nil
+}
+
+func (m PersonModel) GetAll(name string, filters Filters) ([]*Person, MetaData, error){
+	query := fmt.Sprintf(`SELECT COUNT(*) OVER(), id, created_at, name, birth_year, bio, created_by, version FROM people
+	WHERE (to_tsvector('simple', name) @@ plainto_tsquery('simple', $1) OR $1 = '')
+	ORDER BY %s %s, id ASC LIMIT $2 OFFSET $3`, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, name, filters.limit(), filters.offset())
+	if err != nil{
+		return nil, MetaData{}, err
+	}
+
+	defer rows.Close()
+
+	totalRecords := 0
+	people := []*Person{}
+
+	for rows.Next(){
+		var person Person
+
+		err := rows.Scan(
+			&totalRecords,
+			&person.ID,
+			&person.CreatedAt,
+			&person.Name,
+			&person.BirthYear,
+			&person.Bio,
+			&person.CreatedBy,
+			&person.Version,
+		)
+		if err != nil{
+			return nil, MetaData{}, err
+		}
+
+		people = append(people, &person)
+	}
+	if err = rows.Err(); err != nil{
+		return nil, MetaData{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return people, metadata, nil
+}
+
+func (m PersonModel) Update(person *Person) error{
+	query := `UPDATE people SET name = $1, birth_year = $2, bio = $3, version = version + 1
+	WHERE id = $4 AND version = $5 RETURNING version`
+
+	args := []any{person.Name, person.BirthYear, person.Bio, person.ID, person.Version}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&person.Version)
+	if err != nil{
+		switch{
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+// delete the person, the credits go with it
+// ErrPersonCredited while the person has credits, they are on movies of
+// every organization so they are not removed with the person
+func (m PersonModel) Delete(id int64) error{
+	if id < 1{
+		return ErrRecordNotFound
+	}
+
+	query := `DELETE FROM people WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil{
+		switch{
+		case err.Error() == `pq: update or delete on table "people" violates foreign key constraint "movie_credits_person_id_fkey" on table "movie_credits"`:
+			return ErrPersonCredited
+		default:
+			return err
+		}
+	}
+
+	rowAffected, err := result.RowsAffected()
+	if err != nil{
+		return err
+	}
+
+	if rowAffected == 0{
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// the movies of the active organization the person is credited on, a movie
+// come once per credit
+func (m PersonModel) GetFilmography(personID int64, orgID *int64, filters Filters) ([]*FilmographyEntry, MetaData, error){
+	query := fmt.Sprintf(`SELECT COUNT(*) OVER(), movie_credits.id, movie_credits.role, movie_credits.character, `+movieColumns+`
+	FROM movie_credits
+	INNER JOIN movies ON movies.id = movie_credits.movie_id
+	WHERE movie_credits.person_id = $1 AND movies.org_id IS NOT DISTINCT FROM $2 AND movies.deleted_at IS NULL
+	ORDER BY %s %s, movies.id ASC, movie_credits.id ASC LIMIT $3 OFFSET $4`, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, personID, orgID, filters.limit(), filters.offset())
+	if err != nil{
+		return nil, MetaData{}, err
+	}
+
+	defer rows.Close()
+
+	totalRecords := 0
+	entries := []*FilmographyEntry{}
+
+	for rows.Next(){
+		entry := FilmographyEntry{Movie: &Movie{}}
+
+		dest := append([]any{&totalRecords, &entry.CreditID, &entry.Role, &entry.Character}, movieScanDest(entry.Movie)...)
+
+		err := rows.Scan(dest...)
+		if err != nil{
+			return nil, MetaData{}, err
+		}
+
+		entries = append(entries, &entry)
+	}
+	if err = rows.Err(); err != nil{
+		return nil, MetaData{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return entries, metadata, nil
+}
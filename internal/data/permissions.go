@@ -3,9 +3,17 @@ package data
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"strings"
 	"time"
+
+	"greenlight/internal/validator"
+
+	"github.com/lib/pq"
 )
 
+var ErrUnknownPermission = errors.New("unknown permission")
+
 type Permissions []string
 
 // Add func to check permissions silce contian a 
@@ -19,6 +27,40 @@ func (p Permissions) Include(code string) bool{
 	return false
 }
 
+// Allows is like Include but a code ending with ":*" match every code
+// with the same prefix (so "movies:*" allow "movies:write") and "*" match all
+func (p Permissions) Allows(code string) bool{
+	for i := range p{
+		switch{
+		case p[i] == code, p[i] == "*":
+			return true
+		case strings.HasSuffix(p[i], ":*") && strings.HasPrefix(code, strings.TrimSuffix(p[i], "*")):
+			return true
+		}
+	}
+	return false
+}
+
+// Restrict return the codes of subset which p allows
+func (p Permissions) Restrict(subset Permissions) Permissions{
+	restricted := Permissions{}
+	for _, code := range subset{
+		if p.Allows(code){
+			restricted = append(restricted, code)
+		}
+	}
+	return restricted
+}
+
+func ValidatePermissionCodes(v *validator.Validator, codes []string){
+	v.Check(len(codes) >= 1, "codes", "must contain at least 1 permission code")
+	v.Check(validator.Unique(codes), "codes", "must not contain duplicate values")
+
+	for _, code := range codes{
+		v.Check(code != "", "codes", "must not contain empty values")
+	}
+}
+
 type PermissionModel struct{
 	DB *sql.DB
 }
@@ -28,8 +70,174 @@ func (m PermissionModel) GetAllForUser(userID int64) (Permissions, error) {
 	query := `
 	SELECT permissions.code
 	FROM permissions
-	INNER JOIN users_permissions ON user_permissions.permissions_id = permissions.id
+	INNER JOIN users_permissions ON users_permissions.permissions_id = permissions.id
 	INNER JOIN users ON users_permissions.user_id = users.id
 	WHERE users.id = $1
+	ORDER BY permissions.code
 	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID)
+	if err != nil{
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	permissions := Permissions{}
+
+	for rows.Next(){
+		var permission string
+
+		err := rows.Scan(&permission)
+		if err != nil{
+			return nil, err
+		}
+
+		permissions = append(permissions, permission)
+	}
+	if err = rows.Err(); err != nil{
+		return nil, err
+	}
+
+	return permissions, nil
+}
+
+// return the permissions given directly to the user and the one
+// coming from the roles of the user
+func (m PermissionModel) GetEffectiveForUser(userID int64) (Permissions, error){
+	query := `
+	SELECT permissions.code
+	FROM permissions
+	INNER JOIN users_permissions ON users_permissions.permissions_id = permissions.id
+	WHERE users_permissions.user_id = $1
+	UNION
+	SELECT permissions.code
+	FROM permissions
+	INNER JOIN roles_permissions ON roles_permissions.permissions_id = permissions.id
+	INNER JOIN users_roles ON users_roles.role_id = roles_permissions.role_id
+	WHERE users_roles.user_id = $1
+	ORDER BY code
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID)
+	if err != nil{
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	permissions := Permissions{}
+
+	for rows.Next(){
+		var permission string
+
+		err := rows.Scan(&permission)
+		if err != nil{
+			return nil, err
+		}
+
+		permissions = append(permissions, permission)
+	}
+	if err = rows.Err(); err != nil{
+		return nil, err
+	}
+
+	return permissions, nil
+}
+
+// return the codes that do not exist in the permissions table, used at
+// startup to check the configured default permissions
+func (m PermissionModel) Unknown(codes ...string)([]string, error){
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.unknown(ctx, codes)
+}
+
+// *sql.DB and *sql.Tx, so the codes can be checked in or out of a transaction
+type queryer interface{
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+func (m PermissionModel) unknown(ctx context.Context, codes []string)([]string, error){
+	return unknownPermissions(ctx, m.DB, codes)
+}
+
+func unknownPermissions(ctx context.Context, q queryer, codes []string)([]string, error){
+	query := `
+	SELECT DISTINCT code FROM unnest($1::text[]) AS code
+	WHERE code NOT IN (SELECT permissions.code FROM permissions)
+	ORDER BY code`
+
+	rows, err := q.QueryContext(ctx, query, pq.Array(codes))
+	if err != nil{
+		return nil, err
+	}
+	defer rows.Close()
+
+	unknown := []string{}
+	for rows.Next(){
+		var code string
+		err := rows.Scan(&code)
+		if err != nil{
+			return nil, err
+		}
+
+		unknown = append(unknown, code)
+	}
+	if err = rows.Err(); err != nil{
+		return nil, err
+	}
+
+	return unknown, nil
+}
+
+// grant the permission codes to the user, codes the user already has are
+// ignored and ErrUnknownPermission is returned if a code does not exist
+func (m PermissionModel) AddForUser(userID int64, codes ...string) error{
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return withAudit(ctx, m.DB, nil, func(tx *sql.Tx) error{
+		return addPermissionsForUser(ctx, tx, userID, codes)
+	})
+}
+
+func addPermissionsForUser(ctx context.Context, tx *sql.Tx, userID int64, codes []string) error{
+	unknown, err := unknownPermissions(ctx, tx, codes)
+	if err != nil{
+		return err
+	}
+
+	if len(unknown) > 0{
+		return ErrUnknownPermission
+	}
+
+	query := `
+	INSERT INTO users_permissions (user_id, permissions_id)
+	SELECT $1, permissions.id FROM permissions WHERE permissions.code = ANY($2)
+	ON CONFLICT DO NOTHING`
+
+	_, err = tx.ExecContext(ctx, query, userID, pq.Array(codes))
+	return err
+}
+
+// remove the permission codes from the user
+func (m PermissionModel) RemoveForUser(userID int64, codes ...string) error{
+	query := `
+	DELETE FROM users_permissions
+	WHERE user_id = $1
+	AND permissions_id IN (SELECT id FROM permissions WHERE code = ANY($2))`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, userID, pq.Array(codes))
+	return err
 }
@@ -0,0 +1,318 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"greenlight/internal/validator"
+
+	"github.com/lib/pq"
+)
+
+var ErrDuplicateReview = errors.New("duplicate review")
+
+type Review struct{
+	ID int64 `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	MovieID int64 `json:"movie_id"`
+	UserID int64 `json:"user_id"`
+	Rating int `json:"rating"`
+	Body string `json:"body"`
+	Version int `json:"version"`
+}
+
+// the author of the review
+func (r *Review) OwnerID() (int64, bool){
+	return r.UserID, true
+}
+
+func ValidateReview(v *validator.Validator, review *Review){
+	v.Check(review.Rating >= 1, "rating", "must be at least 1")
+	v.Check(review.Rating <= 10, "rating", "must not be more than 10")
+	v.Check(len(review.Body) <= 10_000, "body", "must not be more than 10000 bytes long")
+}
+
+type ReviewModel struct{
+	DB *sql.DB
+}
+
+// every change to the reviews of a movie go through here, the movie row is
+// locked so two reviews written at the same time can't miss each other
+func (m ReviewModel) withMovieLocked(ctx context.Context, movieID int64, fn func(tx *sql.Tx) error) error{
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil{
+		return err
+	}
+	defer tx.Rollback()
+
+	var id int64
+
+	err = tx.QueryRowContext(ctx, `SELECT id FROM movies WHERE id = $1 FOR UPDATE`, movieID).Scan(&id)
+	if err != nil{
+		switch{
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	err = fn(tx)
+	if err != nil{
+		return err
+	}
+
+	query := `UPDATE movies SET review_count = stats.count, average_rating = stats.average
+	FROM (SELECT COUNT(*) AS count, COALESCE(AVG(rating), 0) AS average FROM reviews WHERE movie_id = $1) AS stats
+	WHERE movies.id = $1`
+
+	_, err = tx.ExecContext(ctx, query, movieID)
+	if err != nil{
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// remove the reviews of a user which is deleted and recompute the movies
+// they were on, the cascade on reviews.user_id would leave the counts wrong.
+// the movies are locked in the same order as withMovieLocked does
+func deleteUserReviews(ctx context.Context, tx *sql.Tx, userID int64) error{
+	query := `SELECT id FROM movies WHERE id IN (SELECT movie_id FROM reviews WHERE user_id = $1) ORDER BY id FOR UPDATE`
+
+	rows, err := tx.QueryContext(ctx, query, userID)
+	if err != nil{
+		return err
+	}
+
+	defer rows.Close()
+
+	movieIDs := []int64{}
+
+	for rows.Next(){
+		var id int64
+
+		err := rows.Scan(&id)
+		if err != nil{
+			return err
+		}
+
+		movieIDs = append(movieIDs, id)
+	}
+	if err = rows.Err(); err != nil{
+		return err
+	}
+
+	if len(movieIDs) == 0{
+		return nil
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM reviews WHERE user_id = $1`, userID)
+	if err != nil{
+		return err
+	}
+
+	query = `UPDATE movies SET review_count = stats.count, average_rating = stats.average
+	FROM (SELECT movies.id, COUNT(reviews.id) AS count, COALESCE(AVG(reviews.rating), 0) AS average
+		FROM movies LEFT JOIN reviews ON reviews.movie_id = movies.id
+		WHERE movies.id = ANY($1) GROUP BY movies.id) AS stats
+	WHERE movies.id = stats.id`
+
+	_, err = tx.ExecContext(ctx, query, pq.Array(movieIDs))
+	return err
+}
+
+func (m ReviewModel) Insert(review *Review) error{
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.withMovieLocked(ctx, review.MovieID, func(tx *sql.Tx) error{
+		query := `INSERT INTO reviews (movie_id, user_id, rating, body) VALUES ($1, $2, $3, $4) RETURNING id, created_at, updated_at, version`
+
+		args := []any{review.MovieID, review.UserID, review.Rating, review.Body}
+
+		err := tx.QueryRowContext(ctx, query, args...).Scan(&review.ID, &review.CreatedAt, &review.UpdatedAt, &review.Version)
+		if err != nil{
+			switch{
+			case err.Error() == `pq: duplicate key value violates unique constraint "reviews_movie_id_user_id_key"`:
+				return ErrDuplicateReview
+			default:
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func (m ReviewModel) Get(id, movieID int64) (*Review, error){
+	if id < 1{
+		return nil, ErrRecordNotFound
+	}
+
+	query := `SELECT id, created_at, updated_at, movie_id, user_id, rating, body, version FROM reviews WHERE id = $1 AND movie_id = $2`
+
+	var review Review
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id, movieID).Scan(
+		&review.ID,
+		&review.CreatedAt,
+		&review.UpdatedAt,
+		&review.MovieID,
+		&review.UserID,
+		&review.Rating,
+		&review.Body,
+		&review.Version,
+	)
+	if err != nil{
+		switch{
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &review, nil
+}
+
+func (m ReviewModel) GetAllForMovie(movieID int64, filters Filters) ([]*Review, MetaData, error){
+	query := fmt.Sprintf(`SELECT COUNT(*) OVER(), id, created_at, updated_at, movie_id, user_id, rating, body, version FROM reviews WHERE movie_id = $1 ORDER BY %s %s, id ASC LIMIT $2 OFFSET $3`, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, movieID, filters.limit(), filters.offset())
+	if err != nil{
+		return nil, MetaData{}, err
+	}
+
+	defer rows.Close()
+
+	totalRecords := 0
+	reviews := []*Review{}
+
+	for rows.Next(){
+		var review Review
+
+		err := rows.Scan(
+			&totalRecords,
+			&review.ID,
+			&review.CreatedAt,
+			&review.UpdatedAt,
+			&review.MovieID,
+			&review.UserID,
+			&review.Rating,
+			&review.Body,
+			&review.Version,
+		)
+		if err != nil{
+			return nil, MetaData{}, err
+		}
+
+		reviews = append(reviews, &review)
+	}
+	if err = rows.Err(); err != nil{
+		return nil, MetaData{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return reviews, metadata, nil
+}
+
+// every review the user wrote, used for the data export
+func (m ReviewModel) GetAllByUser(userID int64) ([]*Review, error){
+	query := `SELECT id, created_at, updated_at, movie_id, user_id, rating, body, version FROM reviews WHERE user_id = $1 ORDER BY id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID)
+	if err != nil{
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	reviews := []*Review{}
+
+	for rows.Next(){
+		var review Review
+
+		err := rows.Scan(
+			&review.ID,
+			&review.CreatedAt,
+			&review.UpdatedAt,
+			&review.MovieID,
+			&review.UserID,
+			&review.Rating,
+			&review.Body,
+			&review.Version,
+		)
+		if err != nil{
+			return nil, err
+		}
+
+		reviews = append(reviews, &review)
+	}
+	if err = rows.Err(); err != nil{
+		return nil, err
+	}
+
+	return reviews, nil
+}
+
+func (m ReviewModel) Update(review *Review) error{
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.withMovieLocked(ctx, review.MovieID, func(tx *sql.Tx) error{
+		query := `UPDATE reviews SET rating = $1, body = $2, updated_at = NOW(), version = version + 1
+		WHERE id = $3 AND version = $4 RETURNING updated_at, version`
+
+		args := []any{review.Rating, review.Body, review.ID, review.Version}
+
+		err := tx.QueryRowContext(ctx, query, args...).Scan(&review.UpdatedAt, &review.Version)
+		if err != nil{
+			switch{
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrEditConflict
+			default:
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func (m ReviewModel) Delete(id, movieID int64) error{
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.withMovieLocked(ctx, movieID, func(tx *sql.Tx) error{
+		result, err := tx.ExecContext(ctx, `DELETE FROM reviews WHERE id = $1 AND movie_id = $2`, id, movieID)
+		if err != nil{
+			return err
+		}
+
+		rowsAffected, err := result.RowsAffected()
+		if err != nil{
+			return err
+		}
+
+		if rowsAffected == 0{
+			return ErrRecordNotFound
+		}
+
+		return nil
+	})
+}
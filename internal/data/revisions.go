@@ -0,0 +1,197 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// the state of a movie at one version
+type MovieRevision struct{
+	MovieID int64 `json:"movie_id"`
+	Version int32 `json:"version"`
+	Title string `json:"title"`
+	Year int32 `json:"year"`
+	Runtime Runtime `json:"runtime"`
+	Genres []string `json:"genres"`
+	EditedBy *int64 `json:"edited_by,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// a field that is different between two revisions
+type RevisionChange struct{
+	From any `json:"from"`
+	To any `json:"to"`
+}
+
+// the fields changed going from one revision to the other, keyed by the
+// json name of the field
+func DiffRevisions(from, to *MovieRevision) map[string]RevisionChange{
+	changes := map[string]RevisionChange{}
+
+	if from.Title != to.Title{
+		changes["title"] = RevisionChange{From: from.Title, To: to.Title}
+	}
+	if from.Year != to.Year{
+		changes["year"] = RevisionChange{From: from.Year, To: to.Year}
+	}
+	if from.Runtime != to.Runtime{
+		changes["runtime"] = RevisionChange{From: from.Runtime, To: to.Runtime}
+	}
+
+	sameGenres := len(from.Genres) == len(to.Genres)
+	for i := 0; sameGenres && i < len(from.Genres); i++{
+		sameGenres = from.Genres[i] == to.Genres[i]
+	}
+	if !sameGenres{
+		changes["genres"] = RevisionChange{From: from.Genres, To: to.Genres}
+	}
+
+	return changes
+}
+
+// save the current state of the movie as a revision, called by MovieModel
+// in the transaction that created the version
+func insertRevision(ctx context.Context, tx *sql.Tx, movie *Movie, editedBy *int64) error{
+	query := `INSERT INTO movie_revisions (movie_id, version, title, year, runtime, genres, edited_by)
+	VALUES ($1, $2, $3, $4, $5, $6, $7)`
+
+	args := []any{movie.ID, movie.Version, movie.Title, movie.Year, movie.Runtime, pq.Array(movie.Genres), editedBy}
+
+	_, err := tx.ExecContext(ctx, query, args...)
+	return err
+}
+
+type RevisionModel struct{
+	DB *sql.DB
+}
+
+func (m RevisionModel) Get(movieID int64, version int32) (*MovieRevision, error){
+	if version < 1{
+		return nil, ErrRecordNotFound
+	}
+
+	query := `SELECT movie_id, version, title, year, runtime, genres, edited_by, created_at
+	FROM movie_revisions WHERE movie_id = $1 AND version = $2`
+
+	var revision MovieRevision
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, movieID, version).Scan(
+		&revision.MovieID,
+		&revision.Version,
+		&revision.Title,
+		&revision.Year,
+		&revision.Runtime,
+		pq.Array(&revision.Genres),
+		&revision.EditedBy,
+		&revision.CreatedAt,
+	)
+	if err != nil{
+		switch{
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &revision, nil
+}
+
+func (m RevisionModel) GetAllForMovie(movieID int64, filters Filters) ([]*MovieRevision, MetaData, error){
+	query := fmt.Sprintf(`SELECT COUNT(*) OVER(), movie_id, version, title, year, runtime, genres, edited_by, created_at
+	FROM movie_revisions WHERE movie_id = $1
+	ORDER BY %s %s LIMIT $2 OFFSET $3`, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, movieID, filters.limit(), filters.offset())
+	if err != nil{
+		return nil, MetaData{}, err
+	}
+
+	defer rows.Close()
+
+	totalRecords := 0
+	revisions := []*MovieRevision{}
+
+	for rows.Next(){
+		var revision MovieRevision
+
+		err := rows.Scan(
+			&totalRecords,
+			&revision.MovieID,
+			&revision.Version,
+			&revision.Title,
+			&revision.Year,
+			&revision.Runtime,
+			pq.Array(&revision.Genres),
+			&revision.EditedBy,
+			&revision.CreatedAt,
+		)
+		if err != nil{
+			return nil, MetaData{}, err
+		}
+
+		revisions = append(revisions, &revision)
+	}
+	if err = rows.Err(); err != nil{
+		return nil, MetaData{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return revisions, metadata, nil
+}
+
+// every revision the user made, used for the data export
+func (m RevisionModel) GetAllByEditor(userID int64) ([]*MovieRevision, error){
+	query := `SELECT movie_id, version, title, year, runtime, genres, edited_by, created_at
+	FROM movie_revisions WHERE edited_by = $1
+	ORDER BY created_at, movie_id, version`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID)
+	if err != nil{
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	revisions := []*MovieRevision{}
+
+	for rows.Next(){
+		var revision MovieRevision
+
+		err := rows.Scan(
+			&revision.MovieID,
+			&revision.Version,
+			&revision.Title,
+			&revision.Year,
+			&revision.Runtime,
+			pq.Array(&revision.Genres),
+			&revision.EditedBy,
+			&revision.CreatedAt,
+		)
+		if err != nil{
+			return nil, err
+		}
+
+		revisions = append(revisions, &revision)
+	}
+	if err = rows.Err(); err != nil{
+		return nil, err
+	}
+
+	return revisions, nil
+}
@@ -0,0 +1,52 @@
+package data
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffRevisions(t *testing.T){
+	base := MovieRevision{Title: "Moana", Year: 2016, Runtime: 107, Genres: []string{"animation", "adventure"}}
+
+	tests := []struct{
+		name string
+		to MovieRevision
+		want map[string]RevisionChange
+	}{
+		{"same", base, map[string]RevisionChange{}},
+		{
+			"title",
+			MovieRevision{Title: "Moana 2", Year: 2016, Runtime: 107, Genres: []string{"animation", "adventure"}},
+			map[string]RevisionChange{"title": {From: "Moana", To: "Moana 2"}},
+		},
+		{
+			"year and runtime",
+			MovieRevision{Title: "Moana", Year: 2024, Runtime: 100, Genres: []string{"animation", "adventure"}},
+			map[string]RevisionChange{
+				"year": {From: int32(2016), To: int32(2024)},
+				"runtime": {From: Runtime(107), To: Runtime(100)},
+			},
+		},
+		{
+			"genre added",
+			MovieRevision{Title: "Moana", Year: 2016, Runtime: 107, Genres: []string{"animation", "adventure", "musical"}},
+			map[string]RevisionChange{"genres": {From: []string{"animation", "adventure"}, To: []string{"animation", "adventure", "musical"}}},
+		},
+		{
+			"genres reordered",
+			MovieRevision{Title: "Moana", Year: 2016, Runtime: 107, Genres: []string{"adventure", "animation"}},
+			map[string]RevisionChange{"genres": {From: []string{"animation", "adventure"}, To: []string{"adventure", "animation"}}},
+		},
+	}
+
+	for _, tt := range tests{
+		t.Run(tt.name, func(t *testing.T){
+			from := base
+
+			got := DiffRevisions(&from, &tt.to)
+			if !reflect.DeepEqual(got, tt.want){
+				t.Errorf("got %v; want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,302 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"time"
+
+	"greenlight/internal/validator"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrDuplicateRoleName = errors.New("duplicate role name")
+	ErrUnknownRole = errors.New("unknown role")
+
+	RoleNameRX = regexp.MustCompile("^[a-z][a-z0-9_-]*$")
+)
+
+// Role is a named bundle of permission codes
+type Role struct{
+	ID int64 `json:"id"`
+	CreatedAt time.Time `json:"-"`
+	Name string `json:"name"`
+	Description string `json:"description"`
+	Permissions Permissions `json:"permissions"`
+	Version int32 `json:"version"`
+}
+
+func ValidateRole(v *validator.Validator, role *Role){
+	v.Check(role.Name != "", "name", "must be provided")
+	v.Check(len(role.Name) <= 100, "name", "must not be more than 100 bytes long")
+	v.Check(validator.Matches(role.Name, RoleNameRX), "name", "must only contain lowercase letters, digits, - and _")
+
+	v.Check(len(role.Description) <= 500, "description", "must not be more than 500 bytes long")
+
+	v.Check(role.Permissions != nil, "permissions", "must be provided")
+	v.Check(validator.Unique(role.Permissions), "permissions", "must not contain duplicate values")
+}
+
+type RoleModel struct{
+	DB *sql.DB
+}
+
+func (m RoleModel) Insert(role *Role) error{
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil{
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO roles (name, description) VALUES ($1, $2) RETURNING id, created_at, version`
+
+	err = tx.QueryRowContext(ctx, query, role.Name, role.Description).Scan(&role.ID, &role.CreatedAt, &role.Version)
+	if err != nil{
+		switch{
+		case err.Error() == `pq: duplicate key value violates unique constraint "roles_name_key"`:
+			return ErrDuplicateRoleName
+		default:
+			return err
+		}
+	}
+
+	err = setRolePermissions(ctx, tx, role)
+	if err != nil{
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (m RoleModel) Get(id int64) (*Role, error){
+	if id < 1{
+		return nil, ErrRecordNotFound
+	}
+
+	query := `SELECT roles.id, roles.created_at, roles.name, roles.description, roles.version,
+	ARRAY(SELECT permissions.code FROM permissions
+		INNER JOIN roles_permissions ON roles_permissions.permissions_id = permissions.id
+		WHERE roles_permissions.role_id = roles.id ORDER BY permissions.code)
+	FROM roles WHERE roles.id = $1`
+
+	var role Role
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+		&role.ID,
+		&role.CreatedAt,
+		&role.Name,
+		&role.Description,
+		&role.Version,
+		pq.Array(&role.Permissions),
+	)
+	if err != nil{
+		switch{
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &role, nil
+}
+
+func (m RoleModel) GetAll() ([]*Role, error){
+	query := `SELECT roles.id, roles.created_at, roles.name, roles.description, roles.version,
+	ARRAY(SELECT permissions.code FROM permissions
+		INNER JOIN roles_permissions ON roles_permissions.permissions_id = permissions.id
+		WHERE roles_permissions.role_id = roles.id ORDER BY permissions.code)
+	FROM roles ORDER BY roles.name`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil{
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	roles := []*Role{}
+
+	for rows.Next(){
+		var role Role
+
+		err := rows.Scan(
+			&role.ID,
+			&role.CreatedAt,
+			&role.Name,
+			&role.Description,
+			&role.Version,
+			pq.Array(&role.Permissions),
+		)
+		if err != nil{
+			return nil, err
+		}
+
+		roles = append(roles, &role)
+	}
+	if err = rows.Err(); err != nil{
+		return nil, err
+	}
+
+	return roles, nil
+}
+
+// update the role and replace its permissions, it use the version
+// for optimistic locking same as the movies
+func (m RoleModel) Update(role *Role) error{
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil{
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE roles SET name = $1, description = $2, version = version + 1
+	WHERE id = $3 AND version = $4 RETURNING version`
+
+	err = tx.QueryRowContext(ctx, query, role.Name, role.Description, role.ID, role.Version).Scan(&role.Version)
+	if err != nil{
+		switch{
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		case err.Error() == `pq: duplicate key value violates unique constraint "roles_name_key"`:
+			return ErrDuplicateRoleName
+		default:
+			return err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM roles_permissions WHERE role_id = $1`, role.ID)
+	if err != nil{
+		return err
+	}
+
+	err = setRolePermissions(ctx, tx, role)
+	if err != nil{
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (m RoleModel) Delete(id int64) error{
+	if id < 1{
+		return ErrRecordNotFound
+	}
+
+	query := `DELETE FROM roles WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil{
+		return err
+	}
+
+	rowAffected, err := result.RowsAffected()
+	if err != nil{
+		return err
+	}
+
+	if rowAffected == 0{
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// return the name of the roles the user has
+func (m RoleModel) GetAllForUser(userID int64) ([]string, error){
+	query := `SELECT ARRAY(SELECT roles.name FROM roles
+		INNER JOIN users_roles ON users_roles.role_id = roles.id
+		WHERE users_roles.user_id = $1 ORDER BY roles.name)`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var names []string
+
+	err := m.DB.QueryRowContext(ctx, query, userID).Scan(pq.Array(&names))
+	if err != nil{
+		return nil, err
+	}
+
+	return names, nil
+}
+
+// give the roles to the user, ErrUnknownRole when a name does not exist
+func (m RoleModel) AddForUser(userID int64, names ...string) error{
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var known int
+	err := m.DB.QueryRowContext(ctx, `SELECT COUNT(*) FROM roles WHERE name = ANY($1)`, pq.Array(names)).Scan(&known)
+	if err != nil{
+		return err
+	}
+
+	if known != len(names){
+		return ErrUnknownRole
+	}
+
+	query := `
+	INSERT INTO users_roles (user_id, role_id)
+	SELECT $1, roles.id FROM roles WHERE roles.name = ANY($2)
+	ON CONFLICT DO NOTHING`
+
+	_, err = m.DB.ExecContext(ctx, query, userID, pq.Array(names))
+	return err
+}
+
+func (m RoleModel) RemoveForUser(userID int64, names ...string) error{
+	query := `
+	DELETE FROM users_roles
+	WHERE user_id = $1
+	AND role_id IN (SELECT id FROM roles WHERE name = ANY($2))`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, userID, pq.Array(names))
+	return err
+}
+
+// link the permission codes of the role, the caller must have removed
+// the old one first
+func setRolePermissions(ctx context.Context, tx *sql.Tx, role *Role) error{
+	if len(role.Permissions) == 0{
+		return nil
+	}
+
+	var known int
+	err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM permissions WHERE code = ANY($1)`, pq.Array(role.Permissions)).Scan(&known)
+	if err != nil{
+		return err
+	}
+
+	if known != len(role.Permissions){
+		return ErrUnknownPermission
+	}
+
+	query := `
+	INSERT INTO roles_permissions (role_id, permissions_id)
+	SELECT $1, permissions.id FROM permissions WHERE permissions.code = ANY($2)`
+
+	_, err = tx.ExecContext(ctx, query, role.ID, pq.Array(role.Permissions))
+	return err
+}
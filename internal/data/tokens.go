@@ -6,22 +6,47 @@ import (
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/base32"
+	"errors"
 	"time"
 
 	"greenlight/internal/validator"
+
+	"github.com/lib/pq"
 )
 
 const (
 	ScopeActivation = "activation"
 	ScopeAuthentication = "authentication" // new auth scope
+	ScopePasswordReset = "password-reset"
+	ScopeRefresh = "refresh"
+	ScopeMFAPending = "mfa-pending"
+	ScopeMagicLink = "magic-link"
+	ScopeEmailChange = "email-change"
+	ScopeDataExport = "data-export"
 )
 
+// returned when a refresh token that was already exchanged is used again
+var ErrTokenReused = errors.New("token reused")
+
 type Token struct{
 	Plaintext string `json:"token"`
 	Hash []byte	`json:"-"`
 	UserID int64 `json:"-"`
 	Expiry time.Time `json:"expiry"`
 	Scope string `json:"-"`
+	// metadata used for listing the active sessions of a user
+	CreatedAt time.Time `json:"-"`
+	LastUsedAt *time.Time `json:"-"`
+	UserAgent string `json:"-"`
+	IP string `json:"-"`
+	// tokens issued from the same login share the family
+	Family string `json:"-"`
+	// set for token issued to a oauth client, Permissions nil mean
+	// the token can do everything the user can
+	ClientID string `json:"-"`
+	Permissions Permissions `json:"-"`
+	// the organization the session was switched to
+	OrgID *int64 `json:"-"`
 }
 
 func genrateToken(userID int64, ttl time.Duration, scope string)(*Token, error){
@@ -76,17 +101,85 @@ func (m TokenModel) New(userID int64, ttl time.Duration, scope string)(*Token, e
 	return token, err
 }
 
+// create a authentication token and a refresh token in the same family, when
+// family is empty a new one is started (so a new login)
+func (m TokenModel) NewPair(userID int64, authTTL, refreshTTL time.Duration, family, userAgent, ip string, orgID *int64)(*Token, *Token, error){
+	refreshToken, err := m.NewRefresh(userID, refreshTTL, family, userAgent, ip, orgID)
+	if err != nil{
+		return nil, nil, err
+	}
+
+	authToken, err := genrateToken(userID, authTTL, ScopeAuthentication)
+	if err != nil{
+		return nil, nil, err
+	}
+
+	authToken.Family = refreshToken.Family
+	authToken.UserAgent = userAgent
+	authToken.IP = ip
+	authToken.OrgID = orgID
+
+	err = m.Insert(authToken)
+	if err != nil{
+		return nil, nil, err
+	}
+
+	return authToken, refreshToken, nil
+}
+
+// create only the refresh token, used when the authentication token
+// is not stored in the database (jwt mode)
+func (m TokenModel) NewRefresh(userID int64, ttl time.Duration, family, userAgent, ip string, orgID *int64)(*Token, error){
+	if family == ""{
+		familyBytes := make([]byte, 16)
+		_, err := rand.Read(familyBytes)
+		if err != nil{
+			return nil, err
+		}
+		family = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(familyBytes)
+	}
+
+	token, err := genrateToken(userID, ttl, ScopeRefresh)
+	if err != nil{
+		return nil, err
+	}
+
+	token.Family = family
+	token.UserAgent = userAgent
+	token.IP = ip
+	token.OrgID = orgID
+
+	err = m.Insert(token)
+	return token, err
+}
+
+// create a authentication token for a oauth client, the token can only
+// use the permissions given
+func (m TokenModel) NewForClient(userID int64, ttl time.Duration, clientID string, permissions Permissions)(*Token, error){
+	token, err := genrateToken(userID, ttl, ScopeAuthentication)
+	if err != nil{
+		return nil, err
+	}
+
+	token.ClientID = clientID
+	token.Permissions = permissions
+	if token.Permissions == nil{
+		token.Permissions = Permissions{}
+	}
+
+	err = m.Insert(token)
+	return token, err
+}
+
 func (m TokenModel) Insert(token *Token) error{
-	query := `INSERT INTO tokens (hash, user_id, expiry, scope) VALUES($1, $2, $3, $4)`
+	query := `INSERT INTO tokens (hash, user_id, expiry, scope, user_agent, ip, family, client_id, permissions, org_id) VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING created_at`
 
-	args := []any{token.Hash, token.UserID, token.Expiry, token.Scope}
+	args := []any{token.Hash, token.UserID, token.Expiry, token.Scope, token.UserAgent, token.IP, token.Family, token.ClientID, pq.Array([]string(token.Permissions)), token.OrgID}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	_, err := m.DB.ExecContext(ctx, query, args...)
-
-	return err
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(&token.CreatedAt)
 }
 
 func (m TokenModel) DeletAllForUser(scope string, userID int64) error{
@@ -99,3 +192,248 @@ func (m TokenModel) DeletAllForUser(scope string, userID int64) error{
 	_, err := m.DB.ExecContext(ctx, query, scope, userID)
 	return err
 }
+
+// delete every token the user hold whatever the scope is, we use it when
+// password get changed so all old session and link stop working
+func (m TokenModel) DeleteAllScopesForUser(userID int64) error{
+	query := `DELETE FROM tokens
+	WHERE user_id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, userID)
+	return err
+}
+
+// delete every token of the user and, when deny is not nil, add it to the
+// jwt deny list in the same transaction as the audit entry (which can be nil)
+func (m TokenModel) RevokeAllForUser(userID int64, deny *DeniedToken, entry *AuditEntry) error{
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return withAudit(ctx, m.DB, entry, func(tx *sql.Tx) error{
+		return revokeUserTokens(ctx, tx, userID, deny)
+	})
+}
+
+func revokeUserTokens(ctx context.Context, tx *sql.Tx, userID int64, deny *DeniedToken) error{
+	_, err := tx.ExecContext(ctx, `DELETE FROM tokens WHERE user_id = $1`, userID)
+	if err != nil{
+		return err
+	}
+
+	if deny == nil{
+		return nil
+	}
+
+	return insertDeniedToken(ctx, tx, deny)
+}
+
+// return all the token of the scope for the user which are not expired yet
+func (m TokenModel) GetAllForUser(scope string, userID int64) ([]*Token, error){
+	query := `SELECT hash, user_id, expiry, scope, created_at, last_used_at, user_agent, ip, family
+	FROM tokens
+	WHERE scope = $1 AND user_id = $2 AND expiry > $3
+	ORDER BY created_at DESC`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, scope, userID, time.Now())
+	if err != nil{
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	tokens := []*Token{}
+
+	for rows.Next(){
+		var token Token
+
+		err := rows.Scan(
+			&token.Hash,
+			&token.UserID,
+			&token.Expiry,
+			&token.Scope,
+			&token.CreatedAt,
+			&token.LastUsedAt,
+			&token.UserAgent,
+			&token.IP,
+			&token.Family,
+		)
+		if err != nil{
+			return nil, err
+		}
+
+		tokens = append(tokens, &token)
+	}
+	if err = rows.Err(); err != nil{
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// how old last_used_at must be before UpdateLastUsed write it again, so
+// a busy client doesn't cost a write on every request
+const lastUsedInterval = time.Minute
+
+// update the last time the token was used, the token returned has the
+// permissions it is limited to (nil when the token is not limited) and
+// the organization of the session
+func (m TokenModel) UpdateLastUsed(scope, tokenPlaintext string) (*Token, error){
+	tokenHash := sha256.Sum256([]byte(tokenPlaintext))
+
+	// the update only touch the row when the stored time is too old but
+	// the select always return the token
+	query := `WITH updated AS (
+		UPDATE tokens SET last_used_at = NOW()
+		WHERE hash = $1 AND scope = $2 AND (last_used_at IS NULL OR last_used_at < $3)
+	)
+	SELECT permissions, org_id FROM tokens
+	WHERE hash = $1 AND scope = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	token := Token{
+		Hash: tokenHash[:],
+		Scope: scope,
+	}
+
+	var permissions []string
+
+	err := m.DB.QueryRowContext(ctx, query, tokenHash[:], scope, time.Now().Add(-lastUsedInterval)).Scan(pq.Array(&permissions), &token.OrgID)
+	if err != nil{
+		switch{
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	if permissions != nil{
+		token.Permissions = Permissions(permissions)
+	}
+
+	return &token, nil
+}
+
+// mark the refresh token as used and return it, a refresh token can only be
+// used once so if it was already used we return ErrTokenReused with the family
+// filled so the caller can revoke it
+func (m TokenModel) UseRefresh(tokenPlaintext string) (*Token, error){
+	tokenHash := sha256.Sum256([]byte(tokenPlaintext))
+
+	query := `UPDATE tokens SET used_at = NOW()
+	WHERE hash = $1 AND scope = $2 AND expiry > $3 AND used_at IS NULL
+	RETURNING user_id, expiry, family, org_id`
+
+	token := Token{
+		Hash: tokenHash[:],
+		Scope: ScopeRefresh,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, tokenHash[:], ScopeRefresh, time.Now()).Scan(&token.UserID, &token.Expiry, &token.Family, &token.OrgID)
+	if err == nil{
+		return &token, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows){
+		return nil, err
+	}
+
+	// token was not usable, check if it is because it has been used before
+	query = `SELECT user_id, family FROM tokens
+	WHERE hash = $1 AND scope = $2 AND used_at IS NOT NULL`
+
+	err = m.DB.QueryRowContext(ctx, query, tokenHash[:], ScopeRefresh).Scan(&token.UserID, &token.Family)
+	if err != nil{
+		switch{
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &token, ErrTokenReused
+}
+
+// delete the token and return the user it belong to, for single use tokens
+// so two requests with the same token can't both succeed
+func (m TokenModel) Consume(scope, tokenPlaintext string) (int64, error){
+	tokenHash := sha256.Sum256([]byte(tokenPlaintext))
+
+	query := `DELETE FROM tokens
+	WHERE hash = $1 AND scope = $2 AND expiry > $3
+	RETURNING user_id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var userID int64
+
+	err := m.DB.QueryRowContext(ctx, query, tokenHash[:], scope, time.Now()).Scan(&userID)
+	if err != nil{
+		switch{
+		case errors.Is(err, sql.ErrNoRows):
+			return 0, ErrRecordNotFound
+		default:
+			return 0, err
+		}
+	}
+
+	return userID, nil
+}
+
+// delete every token of a family
+func (m TokenModel) DeleteFamily(family string) error{
+	if family == ""{
+		return nil
+	}
+
+	query := `DELETE FROM tokens
+	WHERE family = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, family)
+	return err
+}
+
+// delete the authentication token with the refresh tokens of its family
+func (m TokenModel) DeleteSession(userID int64, tokenPlaintext string) error{
+	tokenHash := sha256.Sum256([]byte(tokenPlaintext))
+
+	query := `DELETE FROM tokens
+	WHERE user_id = $1 AND (
+		(hash = $2 AND scope = $3)
+		OR family = (SELECT family FROM tokens WHERE hash = $2 AND scope = $3 AND family <> '')
+	)`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, userID, tokenHash[:], ScopeAuthentication)
+	if err != nil{
+		return err
+	}
+
+	rowAffected, err := result.RowsAffected()
+	if err != nil{
+		return err
+	}
+
+	if rowAffected == 0{
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,200 @@
+package data
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"time"
+
+	"greenlight/internal/validator"
+)
+
+// number of recovery code given to the user when 2fa is enabled
+const recoveryCodeCount = 10
+
+type TwoFactor struct{
+	UserID int64
+	CreatedAt time.Time
+	Secret string
+	Confirmed bool
+	LastUsedStep int64
+}
+
+func ValidateTOTPCode(v *validator.Validator, code string){
+	v.Check(code != "", "code", "must be provided")
+	v.Check(len(code) == 6, "code", "must be 6 digits long")
+}
+
+type TwoFactorModel struct{
+	DB *sql.DB
+}
+
+// save a new not confirmed secret for the user, a old not confirmed
+// secret is replaced but a confirmed one is never touched
+func (m TwoFactorModel) InsertPending(userID int64, secret string) error{
+	query := `INSERT INTO users_totp (user_id, secret) VALUES ($1, $2)
+	ON CONFLICT (user_id) DO UPDATE SET secret = EXCLUDED.secret, created_at = NOW(), last_used_step = 0
+	WHERE users_totp.confirmed = false`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, userID, secret)
+	if err != nil{
+		return err
+	}
+
+	rowAffected, err := result.RowsAffected()
+	if err != nil{
+		return err
+	}
+
+	// nothing changed mean the user already have 2fa enabled
+	if rowAffected == 0{
+		return ErrEditConflict
+	}
+
+	return nil
+}
+
+func (m TwoFactorModel) Get(userID int64) (*TwoFactor, error){
+	query := `SELECT user_id, created_at, secret, confirmed, last_used_step FROM users_totp WHERE user_id = $1`
+
+	var twoFactor TwoFactor
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, userID).Scan(
+		&twoFactor.UserID,
+		&twoFactor.CreatedAt,
+		&twoFactor.Secret,
+		&twoFactor.Confirmed,
+		&twoFactor.LastUsedStep,
+	)
+	if err != nil{
+		switch{
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &twoFactor, nil
+}
+
+// return true when the user has finished the 2fa setup
+func (m TwoFactorModel) Enabled(userID int64) (bool, error){
+	twoFactor, err := m.Get(userID)
+	if err != nil{
+		switch{
+		case errors.Is(err, ErrRecordNotFound):
+			return false, nil
+		default:
+			return false, err
+		}
+	}
+	return twoFactor.Confirmed, nil
+}
+
+// remember the step of the code which was used, only a later step is
+// accepted so the same code can't be replayed, it also confirm the setup
+func (m TwoFactorModel) UseStep(userID, step int64) error{
+	query := `UPDATE users_totp SET last_used_step = $1, confirmed = true
+	WHERE user_id = $2 AND last_used_step < $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, step, userID)
+	if err != nil{
+		return err
+	}
+
+	rowAffected, err := result.RowsAffected()
+	if err != nil{
+		return err
+	}
+
+	if rowAffected == 0{
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// delete the old recovery codes of the user and create new one, the
+// plaintext codes are only returned here, we only store the hash
+func (m TwoFactorModel) NewRecoveryCodes(userID int64) ([]string, error){
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil{
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM recovery_codes WHERE user_id = $1`, userID)
+	if err != nil{
+		return nil, err
+	}
+
+	codes := make([]string, recoveryCodeCount)
+
+	for i := range codes{
+		randomBytes := make([]byte, 10)
+
+		_, err := rand.Read(randomBytes)
+		if err != nil{
+			return nil, err
+		}
+
+		codes[i] = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(randomBytes)
+		hash := sha256.Sum256([]byte(codes[i]))
+
+		_, err = tx.ExecContext(ctx, `INSERT INTO recovery_codes (hash, user_id) VALUES ($1, $2)`, hash[:], userID)
+		if err != nil{
+			return nil, err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil{
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// mark the recovery code as used, ErrRecordNotFound when it does not
+// exist or was already used
+func (m TwoFactorModel) UseRecoveryCode(userID int64, code string) error{
+	hash := sha256.Sum256([]byte(code))
+
+	query := `UPDATE recovery_codes SET used_at = NOW()
+	WHERE hash = $1 AND user_id = $2 AND used_at IS NULL`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, hash[:], userID)
+	if err != nil{
+		return err
+	}
+
+	rowAffected, err := result.RowsAffected()
+	if err != nil{
+		return err
+	}
+
+	if rowAffected == 0{
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
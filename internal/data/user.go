@@ -5,6 +5,7 @@ import (
 	"crypto/sha256"
 	"database/sql"
 	"errors"
+	"fmt"
 	"time"
 
 	"greenlight/internal/validator"
@@ -96,15 +97,50 @@ func ValidateUser(v *validator.Validator, user *User){
 // crud on user
 
 func (m UserModel) Insert(user *User) error{
-	query := `INSERT INTO users (name, email, password_hash, activated) VALUES ($1, $2, $3, $4) RETURNING id, created_at, version`
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
 
-	args := []any{user.Name, user.Email, user.Password.hash, user.Activated}
+	return insertUser(ctx, m.DB, user)
+}
 
+// insert the user registered with the invitation, the invitation is used up
+// and the codes in grant are given in the same transaction. ErrRecordNotFound
+// is returned when the invitation expired or was used in the meantime
+func (m UserModel) InsertInvited(user *User, invitation *Invitation, grant []string) error{
 	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
 	defer cancel()
 
+	return withAudit(ctx, m.DB, nil, func(tx *sql.Tx) error{
+		var id int64
+		err := tx.QueryRowContext(ctx, `DELETE FROM invitations WHERE id = $1 AND expiry > $2 RETURNING id`, invitation.ID, time.Now()).Scan(&id)
+		if err != nil{
+			switch{
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrRecordNotFound
+			default:
+				return err
+			}
+		}
+
+		err = insertUser(ctx, tx, user)
+		if err != nil{
+			return err
+		}
+
+		if len(grant) == 0{
+			return nil
+		}
+
+		return addPermissionsForUser(ctx, tx, user.ID, grant)
+	})
+}
+
+func insertUser(ctx context.Context, q queryRower, user *User) error{
+	query := `INSERT INTO users (name, email, password_hash, activated) VALUES ($1, $2, $3, $4) RETURNING id, created_at, version`
+
+	args := []any{user.Name, user.Email, user.Password.hash, user.Activated}
 
-	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&user.ID, &user.CreateAt, &user.Version)
+	err := q.QueryRowContext(ctx, query, args...).Scan(&user.ID, &user.CreateAt, &user.Version)
 	if err != nil{
 		switch{
 			case err.Error() == `pq: duplicate key value violates unique constraint "users_email_key"`:
@@ -115,7 +151,40 @@ func (m UserModel) Insert(user *User) error{
 		}
 
 	return nil
-	
+}
+
+func (m UserModel) Get(id int64)(*User, error){
+	if id < 1{
+		return nil, ErrRecordNotFound
+	}
+
+	query := `SELECT id, created_at, name, email, password_hash, activated, version FROM users WHERE id = $1`
+
+	var user User
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+		&user.ID,
+		&user.CreateAt,
+		&user.Name,
+		&user.Email,
+		&user.Password.hash,
+		&user.Activated,
+		&user.Version,
+	)
+
+	if err != nil{
+		switch{
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &user, nil
 }
 
 func (m UserModel) GetByEmail(email string)(*User, error){
@@ -148,7 +217,94 @@ func (m UserModel) GetByEmail(email string)(*User, error){
 	return &user, nil
 }
 
+// search used by the admin endpoints, an empty name or email and a nil
+// activated match every user
+func (m UserModel) GetAll(name, email string, activated *bool, filters Filters) ([]*User, MetaData, error){
+	query := fmt.Sprintf(`SELECT COUNT(*) OVER(), id, created_at, name, email, password_hash, activated, version FROM users WHERE (to_tsvector('simple', name) @@ plainto_tsquery('simple', $1) OR $1 = '') AND (email ILIKE '%%' || $2 || '%%' OR $2 = '') AND (activated = $3 OR $3 IS NULL) ORDER BY %s %s, id ASC LIMIT $4 OFFSET $5`, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	args := []any{name, email, activated, filters.limit(), filters.offset()}
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil{
+		return nil, MetaData{}, err
+	}
+
+	defer rows.Close()
+
+	totalRecords := 0
+	users := []*User{}
+
+	for rows.Next(){
+		var user User
+
+		err := rows.Scan(
+			&totalRecords,
+			&user.ID,
+			&user.CreateAt,
+			&user.Name,
+			&user.Email,
+			&user.Password.hash,
+			&user.Activated,
+			&user.Version,
+		)
+		if err != nil{
+			return nil, MetaData{}, err
+		}
+
+		users = append(users, &user)
+	}
+	if err = rows.Err(); err != nil{
+		return nil, MetaData{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return users, metadata, nil
+}
+
 func (m UserModel) Update(user *User) error{
+	return m.UpdateAudited(user, nil)
+}
+
+// same as Update, the audit entry is written in the same transaction
+func (m UserModel) UpdateAudited(user *User, entry *AuditEntry) error{
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
+
+	return withAudit(ctx, m.DB, entry, func(tx *sql.Tx) error{
+		return updateUser(ctx, tx, user)
+	})
+}
+
+// save the activated flag of the user. an activated user is granted the
+// codes in grant, a deactivated one lose every token and, when deny is not
+// nil, its jwt are denied. all of it is in the audit entry transaction
+func (m UserModel) UpdateActivation(user *User, grant []string, deny *DeniedToken, entry *AuditEntry) error{
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
+
+	return withAudit(ctx, m.DB, entry, func(tx *sql.Tx) error{
+		err := updateUser(ctx, tx, user)
+		if err != nil{
+			return err
+		}
+
+		if !user.Activated{
+			return revokeUserTokens(ctx, tx, user.ID, deny)
+		}
+
+		if len(grant) == 0{
+			return nil
+		}
+
+		return addPermissionsForUser(ctx, tx, user.ID, grant)
+	})
+}
+
+func updateUser(ctx context.Context, tx *sql.Tx, user *User) error{
 	query := `UPDATE users
 	SET name = $1, email = $2, password_hash = $3, activated = $4, version = version + 1 WHERE id = $5 AND version = $6 RETURNING version`
 
@@ -161,20 +317,184 @@ func (m UserModel) Update(user *User) error{
 		user.Version,
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
-	defer cancel()
-
-	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&user.Version)
+	err := tx.QueryRowContext(ctx, query, args...).Scan(&user.Version)
 	if err != nil {
 		switch{
-		case err.Error() == `pq: dubplicate key value violates unique constraint "users_email_key"`:
-		return ErrDuplicateEmail
+		case err.Error() == `pq: duplicate key value violates unique constraint "users_email_key"`:
+			return ErrDuplicateEmail
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
 		}
 	}
+	return nil
+}
+
+// everything that belong to the user is removed by the ON DELETE CASCADE,
+// the reviews are removed before so the movie ratings are recomputed. entry is the audit record of the delete and can be nil
+func (m UserModel) Delete(id int64, entry *AuditEntry) error{
+	query := `DELETE FROM users WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return withAudit(ctx, m.DB, entry, func(tx *sql.Tx) error{
+		err := deleteUserReviews(ctx, tx, id)
+		if err != nil{
+			return err
+		}
+
+		result, err := tx.ExecContext(ctx, query, id)
+		if err != nil{
+			return err
+		}
+
+		rowsAffected, err := result.RowsAffected()
+		if err != nil{
+			return err
+		}
+
+		if rowsAffected == 0{
+			return ErrRecordNotFound
+		}
+
+		return nil
+	})
+}
+
+// hard erasure for data subject requests, the foreign keys with ON DELETE
+// CASCADE remove the tokens, permissions and the rest, here we also clean
+// what is only linked to the user by there email or in the audit details.
+// entry is the audit record of the erasure and can be nil
+func (m UserModel) Erase(user *User, entry *AuditEntry) error{
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return withAudit(ctx, m.DB, entry, func(tx *sql.Tx) error{
+		return eraseUser(ctx, tx, user)
+	})
+}
+
+func eraseUser(ctx context.Context, tx *sql.Tx, user *User) error{
+	_, err := tx.ExecContext(ctx, `DELETE FROM login_failures WHERE kind = 'account' AND key = lower($1)`, user.Email)
+	if err != nil{
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM invitations WHERE email = $1`, user.Email)
+	if err != nil{
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE audit_log SET details = '{}', ip = '' WHERE target_type = 'user' AND target_id = $1`, user.ID)
+	if err != nil{
+		return err
+	}
+
+	// the invitation the user got keep there email in the details
+	_, err = tx.ExecContext(ctx, `UPDATE audit_log SET details = '{}' WHERE target_type = 'invitation' AND lower(details->>'email') = lower($1)`, user.Email)
+	if err != nil{
+		return err
+	}
+
+	// what the user did as a admin stay in the log but the actor is set to
+	// NULL by the foreign key and we don't keep the ip they used
+	_, err = tx.ExecContext(ctx, `UPDATE audit_log SET ip = '' WHERE actor_id = $1`, user.ID)
+	if err != nil{
+		return err
+	}
+
+	err = deleteUserReviews(ctx, tx, user.ID)
+	if err != nil{
+		return err
+	}
+
+	result, err := tx.ExecContext(ctx, `DELETE FROM users WHERE id = $1`, user.ID)
+	if err != nil{
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil{
+		return err
+	}
+
+	if rowsAffected == 0{
+		return ErrRecordNotFound
+	}
 
 	return nil
 }
 
+// keep the new address until the user confirm it, a new request replace the old one
+func (m UserModel) SetPendingEmail(id int64, email string) error{
+	query := `UPDATE users SET pending_email = $1 WHERE id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, email, id)
+	if err != nil{
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil{
+		return err
+	}
+
+	if rowsAffected == 0{
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// swap the pending email in, the row is locked so the old address we return
+// is the one we replaced. ErrDuplicateEmail if someone took the address since
+func (m UserModel) ConfirmPendingEmail(user *User) (string, error){
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil{
+		return "", err
+	}
+	defer tx.Rollback()
+
+	var oldEmail string
+	var pendingEmail sql.NullString
+
+	err = tx.QueryRowContext(ctx, `SELECT email, pending_email FROM users WHERE id = $1 FOR UPDATE`, user.ID).Scan(&oldEmail, &pendingEmail)
+	if err != nil{
+		switch{
+		case errors.Is(err, sql.ErrNoRows):
+			return "", ErrRecordNotFound
+		default:
+			return "", err
+		}
+	}
+
+	if !pendingEmail.Valid{
+		return "", ErrRecordNotFound
+	}
+
+	query := `UPDATE users SET email = pending_email, pending_email = NULL, version = version + 1 WHERE id = $1 RETURNING email, version`
+
+	err = tx.QueryRowContext(ctx, query, user.ID).Scan(&user.Email, &user.Version)
+	if err != nil{
+		switch{
+		case err.Error() == `pq: duplicate key value violates unique constraint "users_email_key"`:
+			return "", ErrDuplicateEmail
+		default:
+			return "", err
+		}
+	}
+
+	return oldEmail, tx.Commit()
+}
+
 func (m UserModel) GetForToken(tokenScope, tokenPlaintext string) (*User, error){
 	tokenHash := sha256.Sum256([]byte(tokenPlaintext))
 
@@ -0,0 +1,210 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"greenlight/internal/validator"
+)
+
+var ErrDuplicateWatchlistItem = errors.New("duplicate watchlist item")
+
+type WatchlistItem struct{
+	Movie *Movie `json:"movie"`
+	AddedAt time.Time `json:"added_at"`
+	Watched bool `json:"watched"`
+	WatchedAt *time.Time `json:"watched_at,omitempty"`
+	Notes string `json:"notes"`
+}
+
+func ValidateWatchlistItem(v *validator.Validator, item *WatchlistItem){
+	v.Check(len(item.Notes) <= 2000, "notes", "must not be more than 2000 bytes long")
+	v.Check(item.WatchedAt == nil || item.Watched, "watched_at", "must be empty when the movie is not watched")
+	v.Check(item.WatchedAt == nil || !item.WatchedAt.After(time.Now()), "watched_at", "must not be in the future")
+}
+
+type WatchlistModel struct{
+	DB *sql.DB
+}
+
+// add the movie to the watchlist, ErrDuplicateWatchlistItem is returned
+// when it is already in it so the watched state and notes are never reset
+func (m WatchlistModel) Insert(userID int64, item *WatchlistItem) error{
+	query := `INSERT INTO watchlist_items (user_id, movie_id, watched, watched_at, notes) VALUES ($1, $2, $3, $4, $5)
+	ON CONFLICT (user_id, movie_id) DO NOTHING
+	RETURNING added_at`
+
+	args := []any{userID, item.Movie.ID, item.Watched, item.WatchedAt, item.Notes}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&item.AddedAt)
+	if err != nil{
+		switch{
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrDuplicateWatchlistItem
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m WatchlistModel) Update(userID int64, item *WatchlistItem) error{
+	query := `UPDATE watchlist_items SET watched = $3, watched_at = $4, notes = $5
+	WHERE user_id = $1 AND movie_id = $2
+	RETURNING added_at`
+
+	args := []any{userID, item.Movie.ID, item.Watched, item.WatchedAt, item.Notes}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&item.AddedAt)
+	if err != nil{
+		switch{
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m WatchlistModel) Get(userID, movieID int64) (*WatchlistItem, error){
+	query := `SELECT ` + movieColumns + `, watchlist_items.added_at, watchlist_items.watched, watchlist_items.watched_at, watchlist_items.notes
+	FROM watchlist_items
+	INNER JOIN movies ON movies.id = watchlist_items.movie_id
+	WHERE watchlist_items.user_id = $1 AND watchlist_items.movie_id = $2 AND movies.deleted_at IS NULL`
+
+	item := WatchlistItem{Movie: &Movie{}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	dest := append(movieScanDest(item.Movie), &item.AddedAt, &item.Watched, &item.WatchedAt, &item.Notes)
+
+	err := m.DB.QueryRowContext(ctx, query, userID, movieID).Scan(dest...)
+	if err != nil{
+		switch{
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &item, nil
+}
+
+// watched nil return every item
+func (m WatchlistModel) GetAllForUser(userID int64, watched *bool, filters Filters) ([]*WatchlistItem, MetaData, error){
+	query := fmt.Sprintf(`SELECT COUNT(*) OVER(), `+movieColumns+`, watchlist_items.added_at, watchlist_items.watched, watchlist_items.watched_at, watchlist_items.notes
+	FROM watchlist_items
+	INNER JOIN movies ON movies.id = watchlist_items.movie_id
+	WHERE watchlist_items.user_id = $1 AND movies.deleted_at IS NULL AND (watchlist_items.watched = $2 OR $2 IS NULL)
+	ORDER BY %s %s, movies.id ASC LIMIT $3 OFFSET $4`, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID, watched, filters.limit(), filters.offset())
+	if err != nil{
+		return nil, MetaData{}, err
+	}
+
+	defer rows.Close()
+
+	totalRecords := 0
+	items := []*WatchlistItem{}
+
+	for rows.Next(){
+		item := WatchlistItem{Movie: &Movie{}}
+
+		dest := append([]any{&totalRecords}, movieScanDest(item.Movie)...)
+		dest = append(dest, &item.AddedAt, &item.Watched, &item.WatchedAt, &item.Notes)
+
+		err := rows.Scan(dest...)
+		if err != nil{
+			return nil, MetaData{}, err
+		}
+
+		items = append(items, &item)
+	}
+	if err = rows.Err(); err != nil{
+		return nil, MetaData{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return items, metadata, nil
+}
+
+// the whole watchlist, with the movies in the trash, used for the data export
+func (m WatchlistModel) GetAllByUser(userID int64) ([]*WatchlistItem, error){
+	query := `SELECT ` + movieColumns + `, watchlist_items.added_at, watchlist_items.watched, watchlist_items.watched_at, watchlist_items.notes
+	FROM watchlist_items
+	INNER JOIN movies ON movies.id = watchlist_items.movie_id
+	WHERE watchlist_items.user_id = $1
+	ORDER BY watchlist_items.added_at, movies.id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID)
+	if err != nil{
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	items := []*WatchlistItem{}
+
+	for rows.Next(){
+		item := WatchlistItem{Movie: &Movie{}}
+
+		dest := append(movieScanDest(item.Movie), &item.AddedAt, &item.Watched, &item.WatchedAt, &item.Notes)
+
+		err := rows.Scan(dest...)
+		if err != nil{
+			return nil, err
+		}
+
+		items = append(items, &item)
+	}
+	if err = rows.Err(); err != nil{
+		return nil, err
+	}
+
+	return items, nil
+}
+
+func (m WatchlistModel) Delete(userID, movieID int64) error{
+	query := `DELETE FROM watchlist_items WHERE user_id = $1 AND movie_id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, userID, movieID)
+	if err != nil{
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil{
+		return err
+	}
+
+	if rowsAffected == 0{
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
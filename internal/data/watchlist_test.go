@@ -0,0 +1,75 @@
+package data
+
+import (
+	"testing"
+	"time"
+
+	"greenlight/internal/validator"
+)
+
+func TestValidateWatchlistItem(t *testing.T){
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+
+	tests := []struct{
+		name string
+		item WatchlistItem
+		field string
+	}{
+		{"to watch", WatchlistItem{}, ""},
+		{"watched", WatchlistItem{Watched: true, WatchedAt: &past}, ""},
+		{"watched without date", WatchlistItem{Watched: true}, ""},
+		{"date but not watched", WatchlistItem{WatchedAt: &past}, "watched_at"},
+		{"watched in the future", WatchlistItem{Watched: true, WatchedAt: &future}, "watched_at"},
+		{"notes too long", WatchlistItem{Notes: string(make([]byte, 2001))}, "notes"},
+	}
+
+	for _, tt := range tests{
+		t.Run(tt.name, func(t *testing.T){
+			v := validator.New()
+			ValidateWatchlistItem(v, &tt.item)
+
+			if tt.field == ""{
+				if !v.Valid(){
+					t.Errorf("got errors %v; want none", v.Errors)
+				}
+				return
+			}
+
+			if _, ok := v.Errors[tt.field]; !ok{
+				t.Errorf("got errors %v; want one for %q", v.Errors, tt.field)
+			}
+		})
+	}
+}
+
+func TestValidateList(t *testing.T){
+	tests := []struct{
+		name string
+		list List
+		field string
+	}{
+		{"valid", List{Name: "To watch"}, ""},
+		{"no name", List{}, "name"},
+		{"name too long", List{Name: string(make([]byte, 101))}, "name"},
+		{"description too long", List{Name: "To watch", Description: string(make([]byte, 2001))}, "description"},
+	}
+
+	for _, tt := range tests{
+		t.Run(tt.name, func(t *testing.T){
+			v := validator.New()
+			ValidateList(v, &tt.list)
+
+			if tt.field == ""{
+				if !v.Valid(){
+					t.Errorf("got errors %v; want none", v.Errors)
+				}
+				return
+			}
+
+			if _, ok := v.Errors[tt.field]; !ok{
+				t.Errorf("got errors %v; want one for %q", v.Errors, tt.field)
+			}
+		})
+	}
+}
@@ -0,0 +1,242 @@
+package jwt
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	AlgHS256 = "HS256"
+	AlgEdDSA = "EdDSA"
+)
+
+var (
+	ErrInvalidToken = errors.New("invalid token")
+	ErrExpiredToken = errors.New("expired token")
+	ErrUnknownKey = errors.New("unknown key id")
+)
+
+// Claims is what we put inside the token so the api can know
+// the user without going to the database
+type Claims struct{
+	ID string `json:"jti"`
+	Subject int64 `json:"sub"`
+	Activated bool `json:"act"`
+	Permissions []string `json:"perms"`
+	Family string `json:"fam,omitempty"`
+	Org *int64 `json:"org,omitempty"`
+	IssuedAt int64 `json:"iat"`
+	// same time as IssuedAt in microseconds, iat is in whole seconds which
+	// is not enough to compare with a revoke done in the same second
+	IssuedAtMicro int64 `json:"iat_us,omitempty"`
+	Expiry int64 `json:"exp"`
+}
+
+type header struct{
+	Alg string `json:"alg"`
+	Typ string `json:"typ"`
+	Kid string `json:"kid"`
+}
+
+type key struct{
+	alg string
+	secret []byte
+	private ed25519.PrivateKey
+	public ed25519.PublicKey
+}
+
+// KeySet hold every key we accept, only the signing key is used
+// for new tokens so old keys can still verify until they are removed
+type KeySet struct{
+	keys map[string]key
+	signingKID string
+}
+
+func NewKeySet() *KeySet{
+	return &KeySet{keys: make(map[string]key)}
+}
+
+// ParseKeySet read keys in the form "kid:alg:base64key" seperated by comma,
+// for HS256 the key is the secret and for EdDSA it is the 32 byte seed
+func ParseKeySet(spec, signingKID string) (*KeySet, error){
+	ks := NewKeySet()
+
+	for _, part := range strings.Split(spec, ","){
+		part = strings.TrimSpace(part)
+		if part == ""{
+			continue
+		}
+
+		fields := strings.SplitN(part, ":", 3)
+		if len(fields) != 3{
+			return nil, fmt.Errorf("jwt: key %q must be in the form kid:alg:base64key", part)
+		}
+
+		raw, err := base64.StdEncoding.DecodeString(fields[2])
+		if err != nil{
+			return nil, fmt.Errorf("jwt: key %q is not valid base64", fields[0])
+		}
+
+		switch fields[1]{
+		case AlgHS256:
+			err = ks.AddHS256(fields[0], raw)
+		case AlgEdDSA:
+			err = ks.AddEdDSA(fields[0], raw)
+		default:
+			err = fmt.Errorf("jwt: unsupported algorithm %q", fields[1])
+		}
+		if err != nil{
+			return nil, err
+		}
+	}
+
+	if _, ok := ks.keys[signingKID]; !ok{
+		return nil, fmt.Errorf("jwt: signing key %q is not configured", signingKID)
+	}
+	ks.signingKID = signingKID
+
+	return ks, nil
+}
+
+func (ks *KeySet) AddHS256(kid string, secret []byte) error{
+	if len(secret) < 32{
+		return fmt.Errorf("jwt: HS256 key %q must be at least 32 bytes", kid)
+	}
+	ks.keys[kid] = key{alg: AlgHS256, secret: secret}
+	return nil
+}
+
+func (ks *KeySet) AddEdDSA(kid string, seed []byte) error{
+	if len(seed) != ed25519.SeedSize{
+		return fmt.Errorf("jwt: EdDSA key %q must be a %d byte seed", kid, ed25519.SeedSize)
+	}
+	private := ed25519.NewKeyFromSeed(seed)
+	ks.keys[kid] = key{alg: AlgEdDSA, private: private, public: private.Public().(ed25519.PublicKey)}
+	return nil
+}
+
+func (ks *KeySet) SetSigningKey(kid string) error{
+	if _, ok := ks.keys[kid]; !ok{
+		return ErrUnknownKey
+	}
+	ks.signingKID = kid
+	return nil
+}
+
+// Sign encode the claims and sign them with the current signing key
+func (ks *KeySet) Sign(claims Claims) (string, error){
+	k, ok := ks.keys[ks.signingKID]
+	if !ok{
+		return "", ErrUnknownKey
+	}
+
+	headerJSON, err := json.Marshal(header{Alg: k.alg, Typ: "JWT", Kid: ks.signingKID})
+	if err != nil{
+		return "", err
+	}
+
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil{
+		return "", err
+	}
+
+	signingInput := encode(headerJSON) + "." + encode(claimsJSON)
+
+	return signingInput + "." + encode(k.sign([]byte(signingInput))), nil
+}
+
+// Verify check the signature with the key named in the kid header and
+// that the token is not expired at now
+func (ks *KeySet) Verify(token string, now time.Time) (*Claims, error){
+	parts := strings.Split(token, ".")
+	if len(parts) != 3{
+		return nil, ErrInvalidToken
+	}
+
+	headerJSON, err := decode(parts[0])
+	if err != nil{
+		return nil, ErrInvalidToken
+	}
+
+	var h header
+	err = json.Unmarshal(headerJSON, &h)
+	if err != nil{
+		return nil, ErrInvalidToken
+	}
+
+	k, ok := ks.keys[h.Kid]
+	if !ok{
+		return nil, ErrUnknownKey
+	}
+
+	// the alg must be the one of the key, never trust the header for it
+	if h.Alg != k.alg{
+		return nil, ErrInvalidToken
+	}
+
+	signature, err := decode(parts[2])
+	if err != nil{
+		return nil, ErrInvalidToken
+	}
+
+	if !k.verify([]byte(parts[0]+"."+parts[1]), signature){
+		return nil, ErrInvalidToken
+	}
+
+	claimsJSON, err := decode(parts[1])
+	if err != nil{
+		return nil, ErrInvalidToken
+	}
+
+	var claims Claims
+	err = json.Unmarshal(claimsJSON, &claims)
+	if err != nil{
+		return nil, ErrInvalidToken
+	}
+
+	if now.Unix() >= claims.Expiry{
+		return nil, ErrExpiredToken
+	}
+
+	return &claims, nil
+}
+
+// LooksLikeJWT is a cheap check so we know which kind of token we got
+func LooksLikeJWT(token string) bool{
+	return strings.Count(token, ".") == 2
+}
+
+func (k key) sign(input []byte) []byte{
+	switch k.alg{
+	case AlgEdDSA:
+		return ed25519.Sign(k.private, input)
+	default:
+		mac := hmac.New(sha256.New, k.secret)
+		mac.Write(input)
+		return mac.Sum(nil)
+	}
+}
+
+func (k key) verify(input, signature []byte) bool{
+	switch k.alg{
+	case AlgEdDSA:
+		return ed25519.Verify(k.public, input, signature)
+	default:
+		return hmac.Equal(k.sign(input), signature)
+	}
+}
+
+func encode(b []byte) string{
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decode(s string) ([]byte, error){
+	return base64.RawURLEncoding.DecodeString(s)
+}
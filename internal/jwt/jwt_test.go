@@ -0,0 +1,128 @@
+package jwt
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestKeySet(t *testing.T) *KeySet{
+	t.Helper()
+
+	ks := NewKeySet()
+
+	err := ks.AddHS256("hs", bytes.Repeat([]byte("s"), 32))
+	if err != nil{
+		t.Fatal(err)
+	}
+
+	err = ks.AddEdDSA("ed", bytes.Repeat([]byte("e"), 32))
+	if err != nil{
+		t.Fatal(err)
+	}
+
+	return ks
+}
+
+func testClaims(now time.Time) Claims{
+	return Claims{
+		ID: "abc",
+		Subject: 42,
+		Activated: true,
+		Permissions: []string{"movies:read"},
+		IssuedAt: now.Unix(),
+		Expiry: now.Add(time.Hour).Unix(),
+	}
+}
+
+func TestSignVerifyRoundTrip(t *testing.T){
+	now := time.Unix(1_700_000_000, 0)
+
+	for _, kid := range []string{"hs", "ed"}{
+		t.Run(kid, func(t *testing.T){
+			ks := newTestKeySet(t)
+
+			err := ks.SetSigningKey(kid)
+			if err != nil{
+				t.Fatal(err)
+			}
+
+			token, err := ks.Sign(testClaims(now))
+			if err != nil{
+				t.Fatal(err)
+			}
+
+			if !LooksLikeJWT(token){
+				t.Fatalf("token %q does not look like a jwt", token)
+			}
+
+			claims, err := ks.Verify(token, now)
+			if err != nil{
+				t.Fatalf("got error %v", err)
+			}
+
+			if claims.Subject != 42 || claims.ID != "abc" || len(claims.Permissions) != 1{
+				t.Errorf("got claims %+v", claims)
+			}
+		})
+	}
+}
+
+func TestVerifyRejects(t *testing.T){
+	now := time.Unix(1_700_000_000, 0)
+
+	ks := newTestKeySet(t)
+	ks.SetSigningKey("hs")
+
+	token, err := ks.Sign(testClaims(now))
+	if err != nil{
+		t.Fatal(err)
+	}
+	parts := strings.Split(token, ".")
+
+	// same token signed by a key set that doesn't know the kid
+	other := NewKeySet()
+	other.AddHS256("other", bytes.Repeat([]byte("o"), 32))
+	other.SetSigningKey("other")
+	unknownKID, _ := other.Sign(testClaims(now))
+
+	// header saying HS256 for the EdDSA key, signed with the HS256 secret
+	mismatchHeader, _ := json.Marshal(header{Alg: AlgHS256, Typ: "JWT", Kid: "ed"})
+	mismatchInput := encode(mismatchHeader) + "." + parts[1]
+	mismatch := mismatchInput + "." + encode(ks.keys["hs"].sign([]byte(mismatchInput)))
+
+	tampered := testClaims(now)
+	tampered.Subject = 1
+	tamperedJSON, _ := json.Marshal(tampered)
+	tamperedPayload := parts[0] + "." + encode(tamperedJSON) + "." + parts[2]
+
+	signature := []byte(parts[2])
+	signature[0] ^= 1
+	tamperedSignature := parts[0] + "." + parts[1] + "." + string(signature)
+
+	tests := []struct{
+		name string
+		token string
+		now time.Time
+		want error
+	}{
+		{"unknown kid", unknownKID, now, ErrUnknownKey},
+		{"alg key mismatch", mismatch, now, ErrInvalidToken},
+		{"tampered payload", tamperedPayload, now, ErrInvalidToken},
+		{"tampered signature", tamperedSignature, now, ErrInvalidToken},
+		{"expired", token, now.Add(time.Hour), ErrExpiredToken},
+		{"not a jwt", "abc", now, ErrInvalidToken},
+	}
+
+	for _, tt := range tests{
+		t.Run(tt.name, func(t *testing.T){
+			_, err := ks.Verify(tt.token, tt.now)
+			if !errors.Is(err, tt.want){
+				t.Errorf("got %v; want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package policy
+
+import (
+	"errors"
+
+	"greenlight/internal/data"
+)
+
+var ErrForbidden = errors.New("forbidden")
+
+// Actor is the user doing the action with the permissions they have,
+// OrgRoles is there role in the organizations, by organization id
+type Actor struct{
+	UserID int64
+	Permissions data.Permissions
+	OrgRoles map[int64]string
+}
+
+// Resource is a record owned by a user, ok is false when nobody own it
+type Resource interface{
+	OwnerID() (id int64, ok bool)
+}
+
+// OrgResource is a resource which can belong to a organization, ok is
+// false when it doesn't
+type OrgResource interface{
+	OrganizationID() (id int64, ok bool)
+}
+
+// Policy decide if the actor can act on the resource
+type Policy interface{
+	Authorize(actor Actor, resource Resource) error
+}
+
+// OwnerOrPermission let the owner of the resource and anyone
+// holding the permission act on it
+type OwnerOrPermission struct{
+	Permission string
+}
+
+func (p OwnerOrPermission) Authorize(actor Actor, resource Resource) error{
+	if ownerID, ok := resource.OwnerID(); ok && ownerID == actor.UserID{
+		return nil
+	}
+
+	if actor.Permissions.Allows(p.Permission){
+		return nil
+	}
+
+	return ErrForbidden
+}
+
+// OrgAdminOr let the owners and admins of the organization the resource
+// belong to act on it, members have no more rights than outside of it.
+// for everything else the wrapped policy decide
+type OrgAdminOr struct{
+	Policy Policy
+}
+
+func (p OrgAdminOr) Authorize(actor Actor, resource Resource) error{
+	if orgResource, ok := resource.(OrgResource); ok{
+		if orgID, ok := orgResource.OrganizationID(); ok{
+			switch actor.OrgRoles[orgID]{
+			case data.OrgRoleOwner, data.OrgRoleAdmin:
+				return nil
+			}
+		}
+	}
+
+	return p.Policy.Authorize(actor, resource)
+}
+
+// who can update, delete or restore a movie, the owners and admins of a
+// organization moderate its private catalog
+var MovieWrite Policy = OrgAdminOr{Policy: OwnerOrPermission{Permission: "movies:moderate"}}
+
+// who can update or delete a review
+var ReviewWrite Policy = OwnerOrPermission{Permission: "reviews:moderate"}
+
+// who can change a custom list and its items
+var ListWrite Policy = OwnerOrPermission{Permission: "lists:moderate"}
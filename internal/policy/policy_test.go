@@ -0,0 +1,128 @@
+package policy
+
+import (
+	"errors"
+	"testing"
+
+	"greenlight/internal/data"
+)
+
+type resource struct{
+	owner int64
+}
+
+func (r resource) OwnerID() (int64, bool){
+	return r.owner, r.owner != 0
+}
+
+func TestOwnerOrPermission(t *testing.T){
+	tests := []struct{
+		name string
+		actor Actor
+		resource resource
+		want error
+	}{
+		{"owner", Actor{UserID: 1}, resource{owner: 1}, nil},
+		{"other user", Actor{UserID: 2, Permissions: data.Permissions{"movies:write"}}, resource{owner: 1}, ErrForbidden},
+		{"moderator", Actor{UserID: 2, Permissions: data.Permissions{"movies:moderate"}}, resource{owner: 1}, nil},
+		{"wildcard moderator", Actor{UserID: 2, Permissions: data.Permissions{"movies:*"}}, resource{owner: 1}, nil},
+		{"no owner", Actor{UserID: 1}, resource{}, ErrForbidden},
+		{"no owner moderator", Actor{UserID: 1, Permissions: data.Permissions{"movies:moderate"}}, resource{}, nil},
+	}
+
+	for _, tt := range tests{
+		t.Run(tt.name, func(t *testing.T){
+			err := MovieWrite.Authorize(tt.actor, tt.resource)
+			if !errors.Is(err, tt.want){
+				t.Errorf("got %v; want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestReviewWrite(t *testing.T){
+	review := &data.Review{UserID: 1}
+
+	tests := []struct{
+		name string
+		actor Actor
+		want error
+	}{
+		{"author", Actor{UserID: 1}, nil},
+		{"other user", Actor{UserID: 2, Permissions: data.Permissions{"movies:moderate"}}, ErrForbidden},
+		{"moderator", Actor{UserID: 2, Permissions: data.Permissions{"reviews:moderate"}}, nil},
+	}
+
+	for _, tt := range tests{
+		t.Run(tt.name, func(t *testing.T){
+			err := ReviewWrite.Authorize(tt.actor, review)
+			if !errors.Is(err, tt.want){
+				t.Errorf("got %v; want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestMovieWriteOrganization(t *testing.T){
+	orgID := int64(10)
+	creator := int64(1)
+	movie := &data.Movie{CreatedBy: &creator, OrgID: &orgID}
+
+	tests := []struct{
+		name string
+		actor Actor
+		want error
+	}{
+		{"creator", Actor{UserID: 1, OrgRoles: map[int64]string{10: data.OrgRoleMember}}, nil},
+		{"member", Actor{UserID: 2, OrgRoles: map[int64]string{10: data.OrgRoleMember}}, ErrForbidden},
+		{"admin", Actor{UserID: 2, OrgRoles: map[int64]string{10: data.OrgRoleAdmin}}, nil},
+		{"owner", Actor{UserID: 2, OrgRoles: map[int64]string{10: data.OrgRoleOwner}}, nil},
+		{"admin of another organization", Actor{UserID: 2, OrgRoles: map[int64]string{11: data.OrgRoleAdmin}}, ErrForbidden},
+		{"moderator", Actor{UserID: 2, Permissions: data.Permissions{"movies:moderate"}}, nil},
+	}
+
+	for _, tt := range tests{
+		t.Run(tt.name, func(t *testing.T){
+			err := MovieWrite.Authorize(tt.actor, movie)
+			if !errors.Is(err, tt.want){
+				t.Errorf("got %v; want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestMovieWriteSharedCatalog(t *testing.T){
+	creator := int64(1)
+	movie := &data.Movie{CreatedBy: &creator}
+
+	// a organization role give nothing on the shared catalog
+	actor := Actor{UserID: 2, OrgRoles: map[int64]string{10: data.OrgRoleOwner}}
+
+	err := MovieWrite.Authorize(actor, movie)
+	if !errors.Is(err, ErrForbidden){
+		t.Errorf("got %v; want %v", err, ErrForbidden)
+	}
+}
+
+func TestListWrite(t *testing.T){
+	list := &data.List{UserID: 1}
+
+	tests := []struct{
+		name string
+		actor Actor
+		want error
+	}{
+		{"owner", Actor{UserID: 1}, nil},
+		{"other user", Actor{UserID: 2, Permissions: data.Permissions{"movies:moderate"}}, ErrForbidden},
+		{"moderator", Actor{UserID: 2, Permissions: data.Permissions{"lists:moderate"}}, nil},
+	}
+
+	for _, tt := range tests{
+		t.Run(tt.name, func(t *testing.T){
+			err := ListWrite.Authorize(tt.actor, list)
+			if !errors.Is(err, tt.want){
+				t.Errorf("got %v; want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// the values every authenticator app use by default (RFC 6238)
+const (
+	Digits = 6
+	Period = 30 * time.Second
+	// how many step before and after now we still accept, for clock drift
+	Skew = 1
+)
+
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateSecret return a random 160 bit secret encoded in base32
+func GenerateSecret() (string, error){
+	secret := make([]byte, 20)
+
+	_, err := rand.Read(secret)
+	if err != nil{
+		return "", err
+	}
+
+	return encoding.EncodeToString(secret), nil
+}
+
+// Step return the time step number for t
+func Step(t time.Time) int64{
+	return t.Unix() / int64(Period/time.Second)
+}
+
+// CodeAt return the code for a time step (RFC 4226 HOTP with the step as counter)
+func CodeAt(secret string, step int64) (string, error){
+	key, err := encoding.DecodeString(strings.ToUpper(secret))
+	if err != nil{
+		return "", err
+	}
+
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(step))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+
+	// dynamic truncation
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	mod := uint32(1)
+	for i := 0; i < Digits; i++{
+		mod *= 10
+	}
+
+	return fmt.Sprintf("%0*d", Digits, value%mod), nil
+}
+
+// Code return the code for the time t
+func Code(secret string, t time.Time) (string, error){
+	return CodeAt(secret, Step(t))
+}
+
+// Validate check the code against the steps around t, it return the matching
+// step so the caller can refuse the same code being used twice
+func Validate(secret, code string, t time.Time) (int64, bool){
+	if len(code) != Digits{
+		return 0, false
+	}
+
+	current := Step(t)
+
+	for i := int64(-Skew); i <= Skew; i++{
+		expected, err := CodeAt(secret, current+i)
+		if err != nil{
+			return 0, false
+		}
+
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1{
+			return current + i, true
+		}
+	}
+
+	return 0, false
+}
+
+// URI build the otpauth:// uri that authenticator app read from a qr code
+func URI(issuer, account, secret string) string{
+	label := url.PathEscape(issuer + ":" + account)
+
+	values := url.Values{}
+	values.Set("secret", secret)
+	values.Set("issuer", issuer)
+	values.Set("algorithm", "SHA1")
+	values.Set("digits", fmt.Sprint(Digits))
+	values.Set("period", fmt.Sprint(int(Period/time.Second)))
+
+	return "otpauth://totp/" + label + "?" + values.Encode()
+}
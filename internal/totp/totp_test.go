@@ -0,0 +1,78 @@
+package totp
+
+import (
+	"testing"
+	"time"
+)
+
+// "12345678901234567890", the SHA1 secret of the RFC 6238 test vectors
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// RFC 6238 appendix B, the codes are the last 6 of the 8 digits given there
+func TestCodeRFC6238(t *testing.T){
+	tests := []struct{
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+
+	for _, tt := range tests{
+		got, err := Code(rfcSecret, time.Unix(tt.unix, 0))
+		if err != nil{
+			t.Fatal(err)
+		}
+		if got != tt.want{
+			t.Errorf("at %d got %s; want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestValidateSkew(t *testing.T){
+	now := time.Unix(1234567890, 0)
+	current := Step(now)
+
+	tests := []struct{
+		name string
+		offset int64
+		want bool
+	}{
+		{"two steps before", -2, false},
+		{"one step before", -1, true},
+		{"current step", 0, true},
+		{"one step after", 1, true},
+		{"two steps after", 2, false},
+	}
+
+	for _, tt := range tests{
+		t.Run(tt.name, func(t *testing.T){
+			code, err := CodeAt(rfcSecret, current+tt.offset)
+			if err != nil{
+				t.Fatal(err)
+			}
+
+			step, ok := Validate(rfcSecret, code, now)
+			if ok != tt.want{
+				t.Fatalf("got %v; want %v", ok, tt.want)
+			}
+			if ok && step != current+tt.offset{
+				t.Errorf("got step %d; want %d", step, current+tt.offset)
+			}
+		})
+	}
+}
+
+func TestValidateRejectsBadCodes(t *testing.T){
+	now := time.Unix(1234567890, 0)
+
+	for _, code := range []string{"", "00592", "0059240", "000000"}{
+		if _, ok := Validate(rfcSecret, code, now); ok{
+			t.Errorf("code %q was accepted", code)
+		}
+	}
+}
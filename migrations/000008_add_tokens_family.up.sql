@@ -0,0 +1,6 @@
+-- family links a refresh token with every token rotated from it, used_at
+-- is set when a refresh token is exchanged so a second use can be detected
+ALTER TABLE tokens ADD COLUMN IF NOT EXISTS family text NOT NULL DEFAULT '';
+ALTER TABLE tokens ADD COLUMN IF NOT EXISTS used_at timestamp(0) with time zone;
+
+CREATE INDEX IF NOT EXISTS tokens_family_idx ON tokens (family);
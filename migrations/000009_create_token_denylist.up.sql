@@ -0,0 +1,11 @@
+-- revoked jwt authentication tokens, an empty jti revoke every token
+-- of the user issued before revoked_at
+CREATE TABLE IF NOT EXISTS token_denylist(
+	id bigserial PRIMARY KEY,
+	jti text NOT NULL DEFAULT '',
+	user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+	revoked_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+	expiry timestamp(0) with time zone NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS token_denylist_expiry_idx ON token_denylist (expiry);
@@ -0,0 +1,9 @@
+-- failed logins counted by account (email) and by client ip
+CREATE TABLE IF NOT EXISTS login_failures(
+	kind text NOT NULL,
+	key citext NOT NULL,
+	failures integer NOT NULL DEFAULT 0,
+	last_failure_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+	locked_until timestamp(0) with time zone,
+	PRIMARY KEY (kind, key)
+);
@@ -0,0 +1,8 @@
+ALTER TABLE permissions ADD CONSTRAINT permissions_code_key UNIQUE (code);
+
+-- the first admin has to be granted by hand:
+-- INSERT INTO users_permissions SELECT <user id>, id FROM permissions WHERE code = 'permissions:admin';
+INSERT INTO permissions (code)
+VALUES
+ ('permissions:admin')
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,40 @@
+CREATE TABLE IF NOT EXISTS roles(
+	id bigserial PRIMARY KEY,
+	created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+	name text UNIQUE NOT NULL,
+	description text NOT NULL DEFAULT '',
+	version integer NOT NULL DEFAULT 1
+);
+
+CREATE TABLE IF NOT EXISTS roles_permissions(
+	role_id bigint NOT NULL REFERENCES roles ON DELETE CASCADE,
+	permissions_id bigint NOT NULL REFERENCES permissions ON DELETE CASCADE,
+	PRIMARY KEY (role_id, permissions_id)
+);
+
+CREATE TABLE IF NOT EXISTS users_roles(
+	user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+	role_id bigint NOT NULL REFERENCES roles ON DELETE CASCADE,
+	PRIMARY KEY (user_id, role_id)
+);
+
+-- codes ending with :* grant every code with the same prefix
+INSERT INTO permissions (code)
+VALUES
+ ('movies:*'),
+ ('roles:admin')
+ON CONFLICT DO NOTHING;
+
+INSERT INTO roles (name, description)
+VALUES
+ ('viewer', 'can read the movie catalog'),
+ ('editor', 'can read and change the movie catalog'),
+ ('admin', 'can do everything')
+ON CONFLICT DO NOTHING;
+
+INSERT INTO roles_permissions (role_id, permissions_id)
+SELECT roles.id, permissions.id FROM roles, permissions
+WHERE (roles.name = 'viewer' AND permissions.code = 'movies:read')
+OR (roles.name = 'editor' AND permissions.code IN ('movies:read', 'movies:write'))
+OR (roles.name = 'admin' AND permissions.code IN ('movies:*', 'permissions:admin', 'roles:admin'))
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,5 @@
+DELETE FROM permissions WHERE code = 'movies:moderate';
+
+DROP INDEX IF EXISTS movies_created_by_idx;
+
+ALTER TABLE movies DROP COLUMN IF EXISTS created_by;
@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS oauth_clients(
+	id bigserial PRIMARY KEY,
+	created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+	client_id text UNIQUE NOT NULL,
+	-- empty for public client (like a mobile app) which can't keep a secret
+	secret_hash bytea,
+	user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+	name text NOT NULL,
+	redirect_uris text[] NOT NULL,
+	scopes text[] NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS oauth_codes(
+	hash bytea PRIMARY KEY,
+	client_id text NOT NULL REFERENCES oauth_clients (client_id) ON DELETE CASCADE,
+	user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+	redirect_uri text NOT NULL,
+	scopes text[] NOT NULL,
+	code_challenge text NOT NULL,
+	expiry timestamp(0) with time zone NOT NULL
+);
+
+-- token issued to a oauth client, permissions limit what the token can do
+-- (NULL mean every permission of the user)
+ALTER TABLE tokens ADD COLUMN IF NOT EXISTS client_id text NOT NULL DEFAULT '';
+ALTER TABLE tokens ADD COLUMN IF NOT EXISTS permissions text[];
@@ -0,0 +1,2 @@
+DELETE FROM token_denylist WHERE user_id NOT IN (SELECT id FROM users);
+ALTER TABLE token_denylist ADD CONSTRAINT token_denylist_user_id_fkey FOREIGN KEY (user_id) REFERENCES users ON DELETE CASCADE;
@@ -0,0 +1,2 @@
+-- a deleted user still need there jwt denied until they expire
+ALTER TABLE token_denylist DROP CONSTRAINT IF EXISTS token_denylist_user_id_fkey;
@@ -0,0 +1,3 @@
+-- new address waiting to be confirmed, it only become the email once
+-- the token sent to it is used
+ALTER TABLE users ADD COLUMN IF NOT EXISTS pending_email citext;
@@ -0,0 +1,24 @@
+INSERT INTO permissions (code)
+VALUES
+ ('users:admin')
+ON CONFLICT DO NOTHING;
+
+INSERT INTO roles_permissions (role_id, permissions_id)
+SELECT roles.id, permissions.id FROM roles, permissions
+WHERE roles.name = 'admin' AND permissions.code = 'users:admin'
+ON CONFLICT DO NOTHING;
+
+-- what admins did to accounts, the target is not a foreign key so the
+-- record stay after the account is deleted
+CREATE TABLE IF NOT EXISTS audit_log(
+	id bigserial PRIMARY KEY,
+	created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+	actor_id bigint REFERENCES users ON DELETE SET NULL,
+	action text NOT NULL,
+	target_type text NOT NULL,
+	target_id bigint NOT NULL,
+	details jsonb NOT NULL DEFAULT '{}',
+	ip text NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS audit_log_target_idx ON audit_log (target_type, target_id);
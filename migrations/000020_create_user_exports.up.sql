@@ -0,0 +1,7 @@
+-- the last data export of a user, downloaded with a data-export token
+CREATE TABLE IF NOT EXISTS user_exports(
+	user_id bigint PRIMARY KEY REFERENCES users ON DELETE CASCADE,
+	created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+	archive jsonb NOT NULL,
+	expiry timestamp(0) with time zone NOT NULL
+);
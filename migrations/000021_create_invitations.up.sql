@@ -0,0 +1,10 @@
+-- pending invitations when registration is invite only, there is no user
+-- yet so they can't live in the tokens table
+CREATE TABLE IF NOT EXISTS invitations(
+	id bigserial PRIMARY KEY,
+	created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+	email citext UNIQUE NOT NULL,
+	hash bytea UNIQUE NOT NULL,
+	invited_by bigint REFERENCES users ON DELETE SET NULL,
+	expiry timestamp(0) with time zone NOT NULL
+);
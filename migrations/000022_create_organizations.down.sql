@@ -0,0 +1,7 @@
+ALTER TABLE tokens DROP COLUMN IF EXISTS org_id;
+
+DROP INDEX IF EXISTS movies_org_id_idx;
+ALTER TABLE movies DROP COLUMN IF EXISTS org_id;
+
+DROP TABLE IF EXISTS organizations_members;
+DROP TABLE IF EXISTS organizations;
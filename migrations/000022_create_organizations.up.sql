@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS organizations(
+	id bigserial PRIMARY KEY,
+	created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+	name text NOT NULL,
+	version integer NOT NULL DEFAULT 1
+);
+
+-- the role only apply inside the organization, owner and admin can add members
+CREATE TABLE IF NOT EXISTS organizations_members(
+	org_id bigint NOT NULL REFERENCES organizations ON DELETE CASCADE,
+	user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+	role text NOT NULL CHECK (role IN ('owner', 'admin', 'member')),
+	created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+	PRIMARY KEY (org_id, user_id)
+);
+
+-- movies without organization stay in the shared catalog
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS org_id bigint REFERENCES organizations ON DELETE CASCADE;
+CREATE INDEX IF NOT EXISTS movies_org_id_idx ON movies (org_id);
+
+-- the organization a session was switched to
+ALTER TABLE tokens ADD COLUMN IF NOT EXISTS org_id bigint REFERENCES organizations ON DELETE SET NULL;
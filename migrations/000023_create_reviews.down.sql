@@ -0,0 +1,7 @@
+DELETE FROM permissions WHERE code = 'reviews:moderate';
+
+DROP INDEX IF EXISTS movies_average_rating_idx;
+ALTER TABLE movies DROP COLUMN IF EXISTS review_count;
+ALTER TABLE movies DROP COLUMN IF EXISTS average_rating;
+
+DROP TABLE IF EXISTS reviews;
@@ -0,0 +1,28 @@
+-- one review per user and movie
+CREATE TABLE IF NOT EXISTS reviews(
+	id bigserial PRIMARY KEY,
+	created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+	updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+	movie_id bigint NOT NULL REFERENCES movies ON DELETE CASCADE,
+	user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+	rating smallint NOT NULL CHECK (rating BETWEEN 1 AND 10),
+	body text NOT NULL DEFAULT '',
+	version integer NOT NULL DEFAULT 1,
+	UNIQUE (movie_id, user_id)
+);
+
+-- kept up to date by ReviewModel in the same transaction as the review
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS average_rating numeric(4, 2) NOT NULL DEFAULT 0;
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS review_count integer NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS movies_average_rating_idx ON movies (average_rating);
+
+INSERT INTO permissions (code)
+VALUES
+ ('reviews:moderate')
+ON CONFLICT DO NOTHING;
+
+INSERT INTO roles_permissions (role_id, permissions_id)
+SELECT roles.id, permissions.id FROM roles, permissions
+WHERE roles.name = 'admin' AND permissions.code = 'reviews:moderate'
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,5 @@
+DELETE FROM permissions WHERE code = 'lists:moderate';
+
+DROP TABLE IF EXISTS lists_items;
+DROP TABLE IF EXISTS lists;
+DROP TABLE IF EXISTS watchlist_items;
@@ -0,0 +1,42 @@
+-- the movie_id foreign keys cascade so deleting a movie remove it from
+-- every watchlist and list
+CREATE TABLE IF NOT EXISTS watchlist_items(
+	user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+	movie_id bigint NOT NULL REFERENCES movies ON DELETE CASCADE,
+	added_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+	watched boolean NOT NULL DEFAULT false,
+	watched_at timestamp(0) with time zone,
+	notes text NOT NULL DEFAULT '',
+	PRIMARY KEY (user_id, movie_id)
+);
+
+CREATE TABLE IF NOT EXISTS lists(
+	id bigserial PRIMARY KEY,
+	created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+	user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+	name text NOT NULL,
+	description text NOT NULL DEFAULT '',
+	public boolean NOT NULL DEFAULT false,
+	version integer NOT NULL DEFAULT 1
+);
+
+CREATE INDEX IF NOT EXISTS lists_user_id_idx ON lists (user_id);
+
+-- position start at 1, items are read ordered by it
+CREATE TABLE IF NOT EXISTS lists_items(
+	list_id bigint NOT NULL REFERENCES lists ON DELETE CASCADE,
+	movie_id bigint NOT NULL REFERENCES movies ON DELETE CASCADE,
+	position integer NOT NULL,
+	added_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+	PRIMARY KEY (list_id, movie_id)
+);
+
+INSERT INTO permissions (code)
+VALUES
+ ('lists:moderate')
+ON CONFLICT DO NOTHING;
+
+INSERT INTO roles_permissions (role_id, permissions_id)
+SELECT roles.id, permissions.id FROM roles, permissions
+WHERE roles.name = 'admin' AND permissions.code = 'lists:moderate'
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS people(
+	id bigserial PRIMARY KEY,
+	created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+	name text NOT NULL,
+	birth_year integer,
+	bio text NOT NULL DEFAULT '',
+	created_by bigint REFERENCES users ON DELETE SET NULL,
+	version integer NOT NULL DEFAULT 1
+);
+
+CREATE INDEX IF NOT EXISTS people_name_idx ON people USING GIN (to_tsvector('simple', name));
+
+-- a person can have more than one credit on a movie (director and writer,
+-- or playing two characters) but not the same one twice
+CREATE TABLE IF NOT EXISTS movie_credits(
+	id bigserial PRIMARY KEY,
+	movie_id bigint NOT NULL REFERENCES movies ON DELETE CASCADE,
+	person_id bigint NOT NULL REFERENCES people ON DELETE CASCADE,
+	role text NOT NULL,
+	character text NOT NULL DEFAULT '',
+	billing_order integer NOT NULL DEFAULT 0,
+	version integer NOT NULL DEFAULT 1,
+	CONSTRAINT movie_credits_unique UNIQUE (movie_id, person_id, role, character)
+);
+
+CREATE INDEX IF NOT EXISTS movie_credits_person_id_idx ON movie_credits (person_id);
@@ -0,0 +1,18 @@
+-- full snapshot of every version of a movie, written with the insert or
+-- update that created the version
+CREATE TABLE IF NOT EXISTS movie_revisions(
+	movie_id bigint NOT NULL REFERENCES movies ON DELETE CASCADE,
+	version integer NOT NULL,
+	title text NOT NULL,
+	year integer NOT NULL,
+	runtime integer NOT NULL,
+	genres text[] NOT NULL,
+	edited_by bigint REFERENCES users ON DELETE SET NULL,
+	created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+	PRIMARY KEY (movie_id, version)
+);
+
+-- the current state of existing movies become their first known revision
+INSERT INTO movie_revisions (movie_id, version, title, year, runtime, genres, edited_by, created_at)
+SELECT id, version, title, year, runtime, genres, created_by, created_at FROM movies
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,14 @@
+-- deleted movies stay in the trash until restored or purged
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS deleted_at timestamp(0) with time zone;
+
+CREATE INDEX IF NOT EXISTS movies_deleted_at_idx ON movies (deleted_at) WHERE deleted_at IS NOT NULL;
+
+INSERT INTO permissions (code)
+VALUES
+ ('movies:purge')
+ON CONFLICT DO NOTHING;
+
+INSERT INTO roles_permissions (role_id, permissions_id)
+SELECT roles.id, permissions.id FROM roles, permissions
+WHERE roles.name = 'admin' AND permissions.code = 'movies:purge'
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,4 @@
+-- revoked_at keep the microseconds, a revoke-all is compared with the
+-- microsecond a jwt was issued so it is not rounded past a token issued
+-- right after it or before a token issued right before it
+ALTER TABLE token_denylist ALTER COLUMN revoked_at TYPE timestamp with time zone;
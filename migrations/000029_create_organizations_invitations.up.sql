@@ -0,0 +1,13 @@
+-- a user only join a organization by accepting the invitation sent to them,
+-- inviting the same user again replace the previous invitation
+CREATE TABLE IF NOT EXISTS organizations_invitations(
+	id bigserial PRIMARY KEY,
+	created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+	org_id bigint NOT NULL REFERENCES organizations ON DELETE CASCADE,
+	user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+	role text NOT NULL CHECK (role IN ('owner', 'admin', 'member')),
+	hash bytea NOT NULL UNIQUE,
+	invited_by bigint REFERENCES users ON DELETE SET NULL,
+	expiry timestamp(0) with time zone NOT NULL,
+	UNIQUE (org_id, user_id)
+);
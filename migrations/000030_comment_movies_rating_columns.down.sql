@@ -0,0 +1,2 @@
+COMMENT ON COLUMN movies.review_count IS NULL;
+COMMENT ON COLUMN movies.average_rating IS NULL;
@@ -0,0 +1,4 @@
+-- a deleted user's reviews are removed by UserModel before the cascade on
+-- reviews.user_id would, so the aggregates are recomputed
+COMMENT ON COLUMN movies.average_rating IS 'kept up to date by ReviewModel and by UserModel when a user is deleted, in the same transaction as the review change';
+COMMENT ON COLUMN movies.review_count IS 'kept up to date by ReviewModel and by UserModel when a user is deleted, in the same transaction as the review change';
@@ -0,0 +1 @@
+COMMENT ON COLUMN lists_items.position IS NULL;
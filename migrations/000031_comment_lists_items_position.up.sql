@@ -0,0 +1 @@
+COMMENT ON COLUMN lists_items.position IS 'only give the order of the items, a purged movie leave a gap so the position shown is computed when the items are read';
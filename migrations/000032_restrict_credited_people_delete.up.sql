@@ -0,0 +1,4 @@
+-- people are shared by every organization so a person can't be deleted
+-- while credited on a movie
+ALTER TABLE movie_credits DROP CONSTRAINT IF EXISTS movie_credits_person_id_fkey;
+ALTER TABLE movie_credits ADD CONSTRAINT movie_credits_person_id_fkey FOREIGN KEY (person_id) REFERENCES people ON DELETE RESTRICT;
@@ -0,0 +1 @@
+-- the wrong attribution is not restored
@@ -0,0 +1,9 @@
+-- the revisions backfilled by 000026 credited the creator of the movie at
+-- its creation time, for a movie already edited we don't know who made the
+-- last edit or when so edited_by is cleared and created_at is now
+UPDATE movie_revisions SET edited_by = NULL, created_at = NOW()
+FROM movies
+WHERE movies.id = movie_revisions.movie_id
+AND movie_revisions.version > 1
+AND movie_revisions.created_at = movies.created_at
+AND movie_revisions.edited_by IS NOT DISTINCT FROM movies.created_by;